@@ -24,6 +24,7 @@ if [ $? -eq 0 ]; then
     echo "  ./bin/pm update  # Update a password entry"
     echo "  ./bin/pm delete  # Delete a password entry"
     echo "  ./bin/pm generate # Generate a secure password"
+    echo "  ./bin/pm passwd  # Change the master password"
 else
     echo "Build failed!"
     exit 1
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"crypto/subtle"
 	"fmt"
 	"os"
 	"syscall"
@@ -15,45 +14,47 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	addFolder string
+	addTags   []string
+)
+
 var addCmd = &cobra.Command{
 	Use:   "add [title]",
 	Short: "Add a new password entry",
-	Long:  `Add a new password entry to the vault.`,
+	Long: `Add a new password entry to the vault. Titles must be unique within a
+folder. With --folder the entry is filed in that folder, and nested folders
+are separated by slashes:
+
+  pm add GitHub --folder work/dev
+
+The entry can then be addressed as work/dev/GitHub. --tag labels the entry;
+repeat it or separate tags with commas to add several.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		title := args[0]
 		
-		dataDir := getDataDir()
-		store := storage.NewStorage(dataDir)
+		store := openStore()
 		
 		if !store.UserExists() {
 			fmt.Println("Password manager not initialized. Run 'pm init' first.")
 			return
 		}
 
-		user, err := store.LoadUser()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading user: %v\n", err)
-			os.Exit(1)
-		}
-
-		fmt.Print("Enter master password: ")
-		masterPassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Println()
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
 
-		computedHash := crypto.HashPassword(string(masterPassword), user.Salt)
-		if subtle.ConstantTimeCompare([]byte(computedHash), []byte(user.MasterPasswordHash)) != 1 {
-			fmt.Fprintf(os.Stderr, "Invalid master password.\n")
+		folder := storage.CleanFolder(addFolder)
+		if storage.TitleInUse(vault, folder, title) {
+			path := storage.EntryPath(models.PasswordEntry{Folder: folder, Title: title})
+			fmt.Fprintf(os.Stderr, "Password entry '%s' already exists. Use 'pm update %s' to change it.\n", path, path)
 			os.Exit(1)
 		}
 
-		vault, err := store.LoadVault(string(masterPassword))
+		id, err := storage.NewEntryID()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading vault: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -78,7 +79,7 @@ var addCmd = &cobra.Command{
 		fmt.Scanln(&notes)
 
 		entry := models.PasswordEntry{
-			ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
+			ID:        id,
 			Title:     title,
 			Username:  username,
 			Password:  string(password),
@@ -86,15 +87,19 @@ var addCmd = &cobra.Command{
 			Notes:     notes,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
+			Folder:    folder,
+			Tags:      storage.CleanTags(addTags),
 		}
+		crypto.Wipe(password)
 
 		vault.Entries = append(vault.Entries, entry)
+		saveEntryChange(store, vault, storage.EntryChange{After: &entry}, masterPassword)
 
-		if err := store.SaveVault(vault, string(masterPassword)); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
-			os.Exit(1)
-		}
-
-		fmt.Printf("Password entry '%s' added successfully!\n", title)
+		fmt.Printf("Password entry '%s' added successfully!\n", storage.EntryPath(entry))
 	},
 }
+
+func init() {
+	addCmd.Flags().StringVar(&addFolder, "folder", "", "folder to file the entry in, e.g. work/dev")
+	addCmd.Flags().StringSliceVar(&addTags, "tag", nil, "tag to label the entry with; may be repeated")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupKeep   int
+	backupMaxAge time.Duration
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Manage automatic vault backups",
+	Long: `Every save keeps an encrypted copy of the vault it replaces in the
+backups directory, so an accidental delete or a damaged vault can be undone.`,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List vault backups",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		backups, err := store.ListBackups()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing backups: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(backups) == 0 {
+			fmt.Println("No backups found.")
+			return
+		}
+
+		fmt.Printf("%-8s %-20s %s\n", "ID", "Created", "Size")
+		fmt.Println("----------------------------------------")
+		for _, backup := range backups {
+			if backup.OtherVault {
+				fmt.Printf("%-8s %-20s %d bytes (another vault)\n", "-", backup.CreatedAt.Format("2006-01-02 15:04:05"), backup.Size)
+				continue
+			}
+			fmt.Printf("%-8d %-20s %d bytes\n", backup.ID, backup.CreatedAt.Format("2006-01-02 15:04:05"), backup.Size)
+		}
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore [id]",
+	Short: "Restore the vault from a backup",
+	Long: `Replace the vault contents with those of a backup. The current master
+password and other unlock methods are kept, and the vault being replaced is
+itself backed up first.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid backup ID: %s\n", args[0])
+			os.Exit(1)
+		}
+
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		backup, err := store.LoadBackup(id)
+		if err != nil {
+			if errors.Is(err, storage.ErrBackupNotFound) {
+				fmt.Fprintf(os.Stderr, "Backup %d not found. Run 'pm backup list' to see the available backups.\n", id)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error loading backup: %v\n", err)
+			}
+			os.Exit(1)
+		}
+
+		fmt.Printf("Replace the current vault (%d entries) with backup %d (%d entries)? (y/N): ", len(vault.Entries), id, len(backup.Entries))
+		var confirm string
+		fmt.Scanln(&confirm)
+		if confirm != "y" && confirm != "Y" {
+			fmt.Println("Restore cancelled.")
+			return
+		}
+
+		if _, err := store.RestoreBackup(id, vault); err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring backup: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Vault restored from backup %d.\n", id)
+	},
+}
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old vault backups",
+	Long: `Remove backups beyond the newest --keep and those older than --max-age.
+Both default to the backup_keep and backup_max_age settings. The newest
+backup is never removed for its age.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		// Flags that are not given fall back to the configured policy
+		opts := currentOptions()
+		policy := storage.BackupPolicy{Keep: opts.BackupKeep, MaxAge: opts.BackupMaxAge}
+		if cmd.Flags().Changed("keep") {
+			policy.Keep = backupKeep
+		}
+		if cmd.Flags().Changed("max-age") {
+			policy.MaxAge = backupMaxAge
+		}
+
+		removed, err := store.PruneBackups(policy)
+		if err != nil {
+			exitUnlockError("Error pruning backups", err)
+		}
+
+		fmt.Printf("Removed %d backups.\n", len(removed))
+	},
+}
+
+func init() {
+	backupPruneCmd.Flags().IntVar(&backupKeep, "keep", 0, "number of backups to keep, 0 for no limit (default: the backup_keep setting)")
+	backupPruneCmd.Flags().DurationVar(&backupMaxAge, "max-age", 0, "remove backups older than this, e.g. 720h, 0 for no limit (default: the backup_max_age setting)")
+}
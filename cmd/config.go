@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"passwordmanager/config"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change settings",
+	Long: `Show and change the settings in the configuration file, config.toml in
+the config directory.
+
+Settings that apply to vaults can be overridden for one vault by prefixing
+the key with vaults.<vault>., for example:
+
+  pm config set clipboard_timeout 20s
+  pm config set vaults.work.clipboard_timeout 5s`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		value, err := loadConfig().Effective(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [key] [value]",
+	Short: "Change a setting",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, value := args[0], args[1]
+
+		if key == "backend" {
+			// Switching backends needs the same checks as pm init --backend
+			selectBackend(value)
+			fmt.Printf("%s set to %s.\n", key, value)
+			return
+		}
+
+		cfg := loadConfig()
+		if err := cfg.Set(key, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveConfig(cfg)
+
+		fmt.Printf("%s set to %s.\n", key, value)
+		if key == "data_dir" {
+			fmt.Println("Existing vaults are not moved; move the old data directory yourself if needed.")
+		}
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [key]",
+	Short: "Reset a setting to its default",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] == "backend" {
+			// Switching backends needs the same checks as pm config set
+			unsetBackend()
+			fmt.Printf("%s unset.\n", args[0])
+			return
+		}
+
+		cfg := loadConfig()
+		if err := cfg.Unset(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveConfig(cfg)
+
+		fmt.Printf("%s unset.\n", args[0])
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+		fmt.Printf("# %s\n", filepath.Join(getConfigDir(), config.FileName))
+
+		for _, key := range config.Keys() {
+			value, set, _ := cfg.Get(key.Name)
+			switch {
+			case set:
+				fmt.Printf("%-18s = %s\n", key.Name, value)
+			case key.Default != "":
+				fmt.Printf("%-18s = %s (default)\n", key.Name, key.Default)
+			default:
+				fmt.Printf("%-18s   (not set)\n", key.Name)
+			}
+			fmt.Printf("    %s\n", key.Help)
+		}
+
+		for _, key := range cfg.SetKeys() {
+			if strings.HasPrefix(key, "vaults.") {
+				value, _, _ := cfg.Get(key)
+				fmt.Printf("%s = %s\n", key, value)
+			}
+		}
+	},
+}
+
+// saveConfig writes the configuration file, exiting on failure
+func saveConfig(cfg *config.Config) {
+	if err := cfg.Save(getConfigDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"passwordmanager/storage"
+)
+
+// saveEntryChange saves a vault holding change. If another pm command saved
+// the vault since it was loaded, it offers to apply the change to the latest
+// vault instead of overwriting it. On any failure the process exits.
+func saveEntryChange(store *storage.Storage, vault *models.PasswordVault, change storage.EntryChange, masterPassword *crypto.Secret) {
+	for {
+		err := store.SaveVaultChecked(vault, masterPassword)
+		var conflict *storage.ConflictError
+		if !errors.As(err, &conflict) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("The vault was changed by another pm command while this one was running.\n")
+		latest := conflict.Latest
+		if err := change.Apply(latest); err != nil {
+			if errors.Is(err, storage.ErrEntryConflict) {
+				fmt.Fprintf(os.Stderr, "The same entry was changed there too, or another entry now has its name. Your change was not saved.\n")
+			} else {
+				fmt.Fprintf(os.Stderr, "Error merging change: %v\n", err)
+			}
+			os.Exit(1)
+		}
+
+		fmt.Print("Apply your change to the latest vault? (y/N): ")
+		var confirm string
+		fmt.Scanln(&confirm)
+		if confirm != "y" && confirm != "Y" {
+			fmt.Println("Change discarded.")
+			os.Exit(1)
+		}
+		vault = latest
+	}
+}
@@ -1,82 +1,54 @@
 package cmd
 
 import (
-	"crypto/subtle"
 	"fmt"
-	"os"
-	"syscall"
+	"time"
 
-	"golang.org/x/term"
-	"passwordmanager/crypto"
 	"passwordmanager/storage"
 
 	"github.com/spf13/cobra"
 )
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete [title]",
+	Use:   "delete [title|folder/title|id]",
 	Short: "Delete a password entry",
-	Long:  `Delete a password entry by title.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Delete a password entry by title, folder/title path, ID or unique ID prefix.
+
+The entry is moved to the trash, where it can be restored with 'pm trash
+restore' until the trash is emptied or the trash_retention setting purges it.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		title := args[0]
 		
-		dataDir := getDataDir()
-		store := storage.NewStorage(dataDir)
+		store := openStore()
 		
 		if !store.UserExists() {
 			fmt.Println("Password manager not initialized. Run 'pm init' first.")
 			return
 		}
 
-		user, err := store.LoadUser()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading user: %v\n", err)
-			os.Exit(1)
-		}
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
 
-		fmt.Print("Enter master password: ")
-		masterPassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Println()
-
-		computedHash := crypto.HashPassword(string(masterPassword), user.Salt)
-		if subtle.ConstantTimeCompare([]byte(computedHash), []byte(user.MasterPasswordHash)) != 1 {
-			fmt.Fprintf(os.Stderr, "Invalid master password.\n")
-			os.Exit(1)
-		}
-
-		vault, err := store.LoadVault(string(masterPassword))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading vault: %v\n", err)
-			os.Exit(1)
+		index := findEntry(vault, title)
+		if index < 0 {
+			fmt.Printf("Password entry '%s' not found.\n", title)
+			return
 		}
+		entry := vault.Entries[index]
 
-		for i, entry := range vault.Entries {
-			if entry.Title == title {
-				fmt.Printf("Are you sure you want to delete '%s'? (y/N): ", title)
-				var confirm string
-				fmt.Scanln(&confirm)
-				
-				if confirm == "y" || confirm == "Y" {
-					vault.Entries = append(vault.Entries[:i], vault.Entries[i+1:]...)
-					
-					if err := store.SaveVault(vault, string(masterPassword)); err != nil {
-						fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
-						os.Exit(1)
-					}
-					
-					fmt.Printf("Password entry '%s' deleted successfully!\n", title)
-				} else {
-					fmt.Println("Deletion cancelled.")
-				}
-				return
-			}
+		fmt.Printf("Are you sure you want to delete '%s'? (y/N): ", entry.Title)
+		var confirm string
+		fmt.Scanln(&confirm)
+		
+		if confirm == "y" || confirm == "Y" {
+			trashed := storage.TrashEntry(vault, index, time.Now())
+			saveEntryChange(store, vault, storage.EntryChange{Before: &entry, Trashed: &trashed}, masterPassword)
+			
+			fmt.Printf("Password entry '%s' moved to the trash. Restore it with 'pm trash restore %s'.\n", entry.Title, storage.EntryPath(entry))
+		} else {
+			fmt.Println("Deletion cancelled.")
 		}
-
-		fmt.Printf("Password entry '%s' not found.\n", title)
 	},
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"passwordmanager/crypto"
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the vault for problems and repair them",
+	Long: `Check the vault and its files for problems: file permissions, user.dat,
+the vault header and keyslots, whether the master password opens the vault
+or the file is damaged, whether vault.dat belongs to user.dat, and the
+entries themselves.
+
+Where a problem can be fixed, for example by restoring the newest readable
+backup, you are asked before anything is changed. Press Enter at the
+password prompt to check only the files.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() && !store.VaultExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		setKeyFile(store, keyFilePath)
+		masterPassword := readSecret("Enter master password (or press Enter to check only the files): ")
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		var secret *crypto.Secret
+		if masterPassword.Len() > 0 {
+			secret = masterPassword
+		}
+
+		results := store.Diagnose(secret)
+		failed, warnings := 0, 0
+		for _, result := range results {
+			fmt.Printf("%-10s %-18s %s\n", "["+result.Status.String()+"]", result.Check, result.Detail)
+			switch result.Status {
+			case storage.CheckFailed:
+				failed++
+			case storage.CheckWarning:
+				warnings++
+			}
+		}
+
+		if failed == 0 && warnings == 0 {
+			fmt.Println("\nNo problems found.")
+			return
+		}
+		fmt.Printf("\nFound %d problems and %d warnings.\n", failed, warnings)
+
+		repaired := 0
+		for _, result := range results {
+			if result.Repair == "" {
+				continue
+			}
+
+			fmt.Printf("\n%s: %s\nRepair: %s? (y/N): ", result.Check, result.Detail, result.Repair)
+			var confirm string
+			fmt.Scanln(&confirm)
+			if confirm != "y" && confirm != "Y" {
+				continue
+			}
+
+			if err := result.ApplyRepair(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error repairing %s: %v\n", result.Check, err)
+				os.Exit(1)
+			}
+			fmt.Println("Repaired.")
+			repaired++
+		}
+
+		if repaired > 0 {
+			fmt.Println("\nRun 'pm doctor' again to check the result.")
+			return
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
@@ -12,10 +12,11 @@ import (
 var generateCmd = &cobra.Command{
 	Use:   "generate [length]",
 	Short: "Generate a secure random password",
-	Long:  `Generate a secure random password of specified length (default: 16 characters).`,
+	Long: `Generate a secure random password of specified length (default: 16
+characters, or the generate_length setting).`,
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		length := 16
+		length := currentOptions().GenerateLength
 		if len(args) > 0 {
 			if _, err := fmt.Sscanf(args[0], "%d", &length); err != nil {
 				fmt.Fprintf(os.Stderr, "Invalid length: %s\n", args[0])
@@ -1,100 +1,78 @@
 package cmd
 
 import (
-	"crypto/subtle"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
-	"syscall"
+	"strings"
 	"time"
 
-	"golang.org/x/term"
-	"passwordmanager/crypto"
-	"passwordmanager/storage"
-
 	"github.com/spf13/cobra"
 )
 
 var getCmd = &cobra.Command{
-	Use:   "get [title]",
+	Use:   "get [title|folder/title|id]",
 	Short: "Retrieve a password entry",
-	Long:  `Retrieve and display a password entry by title.`,
+	Long:  `Retrieve and display a password entry by title, folder/title path, ID or unique ID prefix.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		title := args[0]
 		
-		dataDir := getDataDir()
-		store := storage.NewStorage(dataDir)
+		store := openStore()
 		
 		if !store.UserExists() {
 			fmt.Println("Password manager not initialized. Run 'pm init' first.")
 			return
 		}
 
-		user, err := store.LoadUser()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading user: %v\n", err)
-			os.Exit(1)
-		}
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+		clipboardTimeout := currentOptions().ClipboardTimeout
 
-		fmt.Print("Enter master password: ")
-		masterPassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
-			os.Exit(1)
+		index := findEntry(vault, title)
+		if index < 0 {
+			fmt.Printf("Password entry '%s' not found.\n", title)
+			return
 		}
-		fmt.Println()
+		entry := vault.Entries[index]
 
-		computedHash := crypto.HashPassword(string(masterPassword), user.Salt)
-		if subtle.ConstantTimeCompare([]byte(computedHash), []byte(user.MasterPasswordHash)) != 1 {
-			fmt.Fprintf(os.Stderr, "Invalid master password.\n")
-			os.Exit(1)
+		fmt.Printf("Title: %s\n", entry.Title)
+		if entry.Folder != "" {
+			fmt.Printf("Folder: %s\n", entry.Folder)
 		}
-
-		vault, err := store.LoadVault(string(masterPassword))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading vault: %v\n", err)
-			os.Exit(1)
+		if len(entry.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(entry.Tags, ", "))
 		}
-
-		for _, entry := range vault.Entries {
-			if entry.Title == title {
-				fmt.Printf("Title: %s\n", entry.Title)
-				fmt.Printf("Username: %s\n", entry.Username)
-				
-				// Copy password to clipboard
-				clipboardCleared := false
-				if err := copyToClipboard(entry.Password); err != nil {
-					fmt.Fprintf(os.Stderr, "Error copying to clipboard: %v\n", err)
-					fmt.Printf("Password: %s\n", entry.Password) // Fallback to displaying
-				} else {
-					fmt.Println("Password: [Copied to clipboard for 10 seconds]")
-					clipboardCleared = true
-				}
-				
-				if entry.URL != "" {
-					fmt.Printf("URL: %s\n", entry.URL)
-				}
-				if entry.Notes != "" {
-					fmt.Printf("Notes: %s\n", entry.Notes)
-				}
-				fmt.Printf("Created: %s\n", entry.CreatedAt.Format("2006-01-02 15:04:05"))
-				fmt.Printf("Updated: %s\n", entry.UpdatedAt.Format("2006-01-02 15:04:05"))
-				
-				// Wait and clear clipboard after 10 seconds
-				if clipboardCleared {
-					fmt.Println("\nWaiting to clear clipboard...")
-					time.Sleep(10 * time.Second)
-					copyToClipboard("") // Clear clipboard
-					fmt.Println("Clipboard cleared.")
-				}
-				
-				return
-			}
+		fmt.Printf("Username: %s\n", entry.Username)
+		
+		// Copy password to clipboard
+		clipboardCleared := false
+		if err := copyToClipboard(entry.Password); err != nil {
+			fmt.Fprintf(os.Stderr, "Error copying to clipboard: %v\n", err)
+			fmt.Printf("Password: %s\n", entry.Password) // Fallback to displaying
+		} else {
+			fmt.Printf("Password: [Copied to clipboard for %s]\n", clipboardTimeout)
+			clipboardCleared = true
+		}
+		
+		if entry.URL != "" {
+			fmt.Printf("URL: %s\n", entry.URL)
+		}
+		if entry.Notes != "" {
+			fmt.Printf("Notes: %s\n", entry.Notes)
+		}
+		fmt.Printf("Created: %s\n", entry.CreatedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Updated: %s\n", entry.UpdatedAt.Format("2006-01-02 15:04:05"))
+		
+		// Wait and clear clipboard after the configured timeout
+		if clipboardCleared {
+			fmt.Println("\nWaiting to clear clipboard...")
+			time.Sleep(clipboardTimeout)
+			copyToClipboard("") // Clear clipboard
+			fmt.Println("Clipboard cleared.")
 		}
-
-		fmt.Printf("Password entry '%s' not found.\n", title)
 	},
 }
 
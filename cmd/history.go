@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"passwordmanager/models"
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreRevision int
+
+var historyCmd = &cobra.Command{
+	Use:   "history [title]",
+	Short: "List the earlier versions of a password entry",
+	Long: `List the versions of a password entry, newest first, with the fields each
+one changed. An entry keeps the versions that updates replace, up to the
+history_keep setting.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		title := args[0]
+
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		index := findEntry(vault, title)
+		if index < 0 {
+			fmt.Printf("Password entry '%s' not found.\n", title)
+			return
+		}
+		entry := vault.Entries[index]
+
+		versions := append(append([]models.EntryRevision(nil), entry.History...), storage.CurrentVersion(entry))
+		fmt.Printf("%-6s %-20s %s\n", "Rev", "Saved", "Changed")
+		for i := len(versions) - 1; i >= 0; i-- {
+			version := versions[i]
+			changed := "-"
+			switch {
+			case i > 0:
+				changed = changedFields(versions[i-1], version)
+			case version.Number == 1:
+				changed = "created"
+			}
+			if i == len(versions)-1 {
+				changed += " (current)"
+			}
+			fmt.Printf("%-6d %-20s %s\n", version.Number, version.UpdatedAt.Format("2006-01-02 15:04:05"), changed)
+		}
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [title] [rev]",
+	Short: "Show what changed in a password entry",
+	Long: `Show the fields that changed from revision rev of a password entry to the
+version after it, or without rev what the last update changed. Passwords are
+never shown, only whether they changed.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		title := args[0]
+		number := 0
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Invalid revision: %s\n", args[1])
+				os.Exit(1)
+			}
+			number = n
+		}
+
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		index := findEntry(vault, title)
+		if index < 0 {
+			fmt.Printf("Password entry '%s' not found.\n", title)
+			return
+		}
+		entry := vault.Entries[index]
+
+		if len(entry.History) == 0 {
+			fmt.Printf("Password entry '%s' has no earlier versions.\n", title)
+			return
+		}
+		if number == 0 {
+			number = entry.History[len(entry.History)-1].Number
+		}
+
+		from, err := storage.FindRevision(entry, number)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Revision %d of '%s' not found. Run 'pm history %s' to see the revisions.\n", number, title, title)
+			os.Exit(1)
+		}
+		to, err := storage.NextRevision(entry, number)
+		if err != nil {
+			fmt.Printf("Revision %d is the current version.\n", number)
+			return
+		}
+
+		fmt.Printf("Revision %d (%s) -> %d (%s):\n", from.Number, from.UpdatedAt.Format("2006-01-02 15:04:05"),
+			to.Number, to.UpdatedAt.Format("2006-01-02 15:04:05"))
+		changes := storage.DiffRevisions(from, to)
+		if len(changes) == 0 {
+			fmt.Println("  No changes.")
+		}
+		for _, change := range changes {
+			if change.Secret {
+				fmt.Printf("  %s: changed\n", change.Field)
+			} else {
+				fmt.Printf("  %s: %q -> %q\n", change.Field, change.Old, change.New)
+			}
+		}
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [title]",
+	Short: "Restore an earlier version of a password entry",
+	Long: `Make revision --rev of a password entry its current version again. The
+version it replaces is kept in the history, so a restore can be undone the
+same way. Run 'pm history' to see the revisions.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		title := args[0]
+
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		index := findEntry(vault, title)
+		if index < 0 {
+			fmt.Printf("Password entry '%s' not found.\n", title)
+			return
+		}
+
+		before := vault.Entries[index]
+		if restoreRevision == storage.CurrentRevision(before) {
+			fmt.Printf("Revision %d is already the current version.\n", restoreRevision)
+			return
+		}
+
+		entry := before
+		if err := storage.RestoreRevision(&entry, restoreRevision, currentOptions().HistoryKeep); err != nil {
+			if errors.Is(err, storage.ErrRevisionNotFound) {
+				fmt.Fprintf(os.Stderr, "Revision %d of '%s' not found. Run 'pm history %s' to see the revisions.\n", restoreRevision, title, title)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error restoring revision: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		vault.Entries[index] = entry
+		saveEntryChange(store, vault, storage.EntryChange{Before: &before, After: &entry}, masterPassword)
+
+		fmt.Printf("Password entry '%s' restored to revision %d.\n", title, restoreRevision)
+	},
+}
+
+func init() {
+	restoreCmd.Flags().IntVar(&restoreRevision, "rev", 0, "revision to restore (see 'pm history')")
+	restoreCmd.MarkFlagRequired("rev")
+}
+
+// changedFields lists the fields that changed from version a to b
+func changedFields(a, b models.EntryRevision) string {
+	changes := storage.DiffRevisions(a, b)
+	if len(changes) == 0 {
+		return "no changes"
+	}
+	names := make([]string, len(changes))
+	for i, change := range changes {
+		names[i] = strings.ToLower(change.Field)
+	}
+	return strings.Join(names, ", ")
+}
@@ -3,10 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"syscall"
 	"time"
 
-	"golang.org/x/term"
 	"passwordmanager/crypto"
 	"passwordmanager/models"
 	"passwordmanager/storage"
@@ -14,62 +12,213 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	initKDF         string
+	initIterations  uint32
+	initMemory      uint32
+	initParallelism uint8
+	initRecovery    int
+	initCipher      string
+	initBackend     string
+)
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize the password manager",
 	Long:  `Initialize the password manager by setting up a master password and creating the encrypted vault.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		dataDir := getDataDir()
-		store := storage.NewStorage(dataDir)
-		
-		if err := store.Initialize(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error initializing storage: %v\n", err)
-			os.Exit(1)
+		if initBackend != "" {
+			selectBackend(initBackend)
 		}
 
-		if store.UserExists() {
+		codes, ok := initializeVault(openVault(currentVault()))
+		if !ok {
 			fmt.Println("Password manager is already initialized.")
 			return
 		}
 
-		fmt.Print("Enter master password: ")
-		masterPassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
-			os.Exit(1)
+		fmt.Println("Password manager initialized successfully!")
+		if len(codes) > 0 {
+			printRecoveryCodes(codes)
 		}
-		fmt.Println()
+	},
+}
+
+// initializeVault sets up a master password and an empty vault in store,
+// using the init flags, and returns the recovery codes issued. It returns
+// false if store is already initialized.
+func initializeVault(store *storage.Storage) ([]string, bool) {
+	if err := store.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	lockStore(store)
+	defer store.Unlock()
+
+	if store.UserExists() {
+		return nil, false
+	}
 
-		fmt.Print("Confirm master password: ")
-		confirmPassword, err := term.ReadPassword(int(syscall.Stdin))
+	kdfParams, err := kdfParamsFromFlags()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid KDF settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	masterPassword := readSecret("Enter master password: ")
+	defer masterPassword.Wipe()
+
+	confirmPassword := readSecret("Confirm master password: ")
+	match := masterPassword.Equal(confirmPassword)
+	confirmPassword.Wipe()
+
+	if !match {
+		fmt.Fprintf(os.Stderr, "Passwords do not match.\n")
+		os.Exit(1)
+	}
+
+	defer store.Wipe()
+	applyKeyFile(store)
+
+	if err := store.SetKDFParams(kdfParams); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid KDF settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	suite, err := crypto.ParseCipherSuite(initCipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid cipher: %v\n", err)
+		os.Exit(1)
+	}
+	if err := store.SetCipherSuite(suite); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid cipher: %v\n", err)
+		os.Exit(1)
+	}
+
+	vault := &models.PasswordVault{
+		Entries: []models.PasswordEntry{},
+		Version: storage.CurrentVaultVersion,
+	}
+	if err := store.SaveVault(vault, masterPassword); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	var codes []string
+	if initRecovery > 0 {
+		codes, err = store.GenerateRecoveryCodes(initRecovery)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error generating recovery codes: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println()
-
-		if string(masterPassword) != string(confirmPassword) {
-			fmt.Fprintf(os.Stderr, "Passwords do not match.\n")
+		if err := store.SaveVault(vault, masterPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		salt, err := crypto.GenerateSalt()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating salt: %v\n", err)
-			os.Exit(1)
-		}
+	user := &models.User{
+		CreatedAt: time.Now(),
+	}
 
-		user := &models.User{
-			MasterPasswordHash: crypto.HashPassword(string(masterPassword), salt),
-			Salt:               salt,
-			CreatedAt:          time.Now(),
-		}
+	if err := store.SaveUser(user); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving user: %v\n", err)
+		os.Exit(1)
+	}
+
+	return codes, true
+}
+
+func init() {
+	addInitFlags(initCmd)
+	initCmd.Flags().StringVar(&initBackend, "backend", "", "storage backend (file or kv)")
+}
+
+// addInitFlags registers the flags initializeVault reads on cmd
+func addInitFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&initCipher, "cipher", "aes-256-gcm", "vault cipher (aes-256-gcm or xchacha20-poly1305)")
+	cmd.Flags().StringVar(&initKDF, "kdf", "argon2id", "key derivation function (argon2id, scrypt or pbkdf2)")
+	cmd.Flags().Uint32Var(&initIterations, "kdf-iterations", 0, "KDF iterations / time cost (0 = default)")
+	cmd.Flags().Uint32Var(&initMemory, "kdf-memory", 0, "KDF memory cost in KiB, or N for scrypt (0 = default)")
+	cmd.Flags().Uint8Var(&initParallelism, "kdf-parallelism", 0, "KDF parallelism (0 = default)")
+	cmd.Flags().IntVar(&initRecovery, "recovery-codes", defaultRecoveryCodes, "number of recovery codes to generate (0 to skip)")
+}
+
+// kdfParamsFromFlags builds KDF parameters from the init flags, falling back
+// to the algorithm's defaults for anything left unset
+func kdfParamsFromFlags() (crypto.KDFParams, error) {
+	alg, err := crypto.ParseKDFAlgorithm(initKDF)
+	if err != nil {
+		return crypto.KDFParams{}, err
+	}
+
+	params, err := crypto.DefaultKDFParams(alg)
+	if err != nil {
+		return crypto.KDFParams{}, err
+	}
+
+	if initIterations != 0 {
+		params.Iterations = initIterations
+	}
+	if initMemory != 0 {
+		params.Memory = initMemory
+	}
+	if initParallelism != 0 {
+		params.Parallelism = initParallelism
+	}
 
-		if err := store.SaveUser(user); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving user: %v\n", err)
+	return params, params.Validate()
+}
+
+// selectBackend records the storage backend to use in the configuration
+// file. All vaults share one backend, so it can only be chosen before the
+// first vault is initialized.
+func selectBackend(kind string) {
+	if _, err := storage.OpenBackend(kind, getDataDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid backend: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	if cfg.Backend == kind {
+		return
+	}
+	requireNoVaults()
+
+	cfg.Backend = kind
+	if err := cfg.Save(getConfigDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// unsetBackend removes the storage backend from the configuration file,
+// going back to the default, under the same conditions as selectBackend
+func unsetBackend() {
+	cfg := loadConfig()
+	if cfg.Backend != "" && cfg.Backend != storage.BackendFile {
+		requireNoVaults()
+	}
+	if err := cfg.Unset("backend"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	saveConfig(cfg)
+}
+
+// requireNoVaults exits if any vault is initialized under the current
+// backend, since changing backends would hide it
+func requireNoVaults() {
+	names, err := storage.ListVaults(getDataDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing vaults: %v\n", err)
+		os.Exit(1)
+	}
+	for _, name := range names {
+		if openVault(name).UserExists() {
+			fmt.Fprintf(os.Stderr, "The storage backend can only be chosen before the first vault is initialized.\n")
 			os.Exit(1)
 		}
-
-		fmt.Println("Password manager initialized successfully!")
-	},
+	}
 }
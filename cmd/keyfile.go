@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"passwordmanager/crypto"
+
+	"github.com/spf13/cobra"
+)
+
+var keyfileCmd = &cobra.Command{
+	Use:   "keyfile",
+	Short: "Manage key files",
+	Long: `Manage key files that are required alongside the master password.
+
+A vault initialized with 'pm init --key-file <path>' can only be unlocked
+when the same file is passed with --key-file.`,
+}
+
+var keyfileGenerateCmd = &cobra.Command{
+	Use:   "generate [path]",
+	Short: "Generate a new random key file",
+	Long: `Generate a new key file containing random data at the given path.
+
+Keep the key file somewhere separate from the vault, such as a USB stick.
+The vault cannot be opened without it, so make a backup copy.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+
+		contents, err := crypto.GenerateKeyFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating key file: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Never overwrite an existing file: it may be protecting a vault
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating key file: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := file.Write(contents); err != nil {
+			file.Close()
+			fmt.Fprintf(os.Stderr, "Error writing key file: %v\n", err)
+			os.Exit(1)
+		}
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing key file: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Key file written to %s\n", path)
+	},
+}
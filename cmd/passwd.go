@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var passwdCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "Change the master password",
+	Long: `Change the master password and re-encrypt the vault under the new key.
+
+The vault is replaced atomically, so an interruption leaves it readable with
+either the old or the new master password.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		newPassword := readNewPassword("new master password")
+		defer newPassword.Wipe()
+
+		if err := store.ChangeMasterPassword(vault, newPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error re-encrypting vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		// The vault is already committed under the new password; user.dat
+		// only records when that happened
+		user, err := store.LoadUser()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading user: %v\n", err)
+			os.Exit(1)
+		}
+		user.PasswordChangedAt = time.Now()
+		if err := store.SaveUser(user); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving user: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Master password changed successfully!")
+	},
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"passwordmanager/models"
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Set a new master password using a recovery code",
+	Long: `Unlock the vault with a recovery code and set a new master password.
+
+The recovery code is used up in the process. Pass --key-file to keep
+requiring a key file alongside the new master password.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+		defer store.Wipe()
+		applyKeyFile(store)
+
+		code := readVisibleSecret("Enter recovery code: ")
+		vault, err := store.LoadVaultWithRecoveryCode(code)
+		code.Wipe()
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidPassword) {
+				fmt.Fprintf(os.Stderr, "Invalid or already used recovery code.\n")
+			} else {
+				fmt.Fprintf(os.Stderr, "Error loading vault: %v\n", err)
+			}
+			os.Exit(1)
+		}
+
+		resetMasterPassword(store, vault)
+		fmt.Printf("%d recovery codes remaining.\n", store.RecoveryCodeCount())
+	},
+}
+
+// resetMasterPassword prompts for a new master password for a vault opened
+// with a recovery secret, saves it and uses up the secret
+func resetMasterPassword(store *storage.Storage, vault *models.PasswordVault) {
+	newPassword := readNewPassword("new master password")
+	defer newPassword.Wipe()
+
+	if err := store.RecoverMasterPassword(vault, newPassword); err != nil {
+		fmt.Fprintf(os.Stderr, "Error re-encrypting vault: %v\n", err)
+		os.Exit(1)
+	}
+
+	user, err := store.LoadUser()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading user: %v\n", err)
+		os.Exit(1)
+	}
+	user.PasswordChangedAt = time.Now()
+	if err := store.SaveUser(user); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving user: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Master password reset successfully!")
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"passwordmanager/crypto"
+	"passwordmanager/shamir"
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultRecoveryCodes is how many recovery codes are generated at a time
+const defaultRecoveryCodes = 8
+
+var (
+	recoveryCount     int
+	recoveryShares    int
+	recoveryThreshold int
+)
+
+var recoveryCmd = &cobra.Command{
+	Use:   "recovery",
+	Short: "Manage recovery codes",
+	Long: `Manage recovery codes that can unlock the vault if the master password is lost.
+
+Each code works exactly once, with 'pm recover'.`,
+}
+
+var recoveryGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a new set of recovery codes",
+	Long:  `Generate a new set of recovery codes. All previously issued codes stop working.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		if recoveryCount < 1 || recoveryCount > 32 {
+			fmt.Fprintf(os.Stderr, "Recovery code count must be between 1 and 32.\n")
+			os.Exit(1)
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		codes, err := store.GenerateRecoveryCodes(recoveryCount)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating recovery codes: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := store.SaveVault(vault, masterPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		printRecoveryCodes(codes)
+	},
+}
+
+var recoverySplitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split a recovery key into Shamir shares",
+	Long: `Create a vault recovery key and split it into shares so that no single
+person can recover the vault alone. Any --threshold of the --shares printed
+shares can be combined with 'pm recovery combine' to set a new master password.
+
+Running split again replaces the previous recovery key; old shares stop working.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		if recoveryThreshold < 2 || recoveryShares < recoveryThreshold || recoveryShares > 255 {
+			fmt.Fprintf(os.Stderr, "Threshold must be at least 2 and no more than the number of shares (at most 255).\n")
+			os.Exit(1)
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		key, err := store.GenerateSharedRecoveryKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating recovery key: %v\n", err)
+			os.Exit(1)
+		}
+
+		shares, err := shamir.Split(key, recoveryShares, recoveryThreshold)
+		crypto.Wipe(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error splitting recovery key: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := store.SaveVault(vault, masterPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("\nRecovery key split into %d shares; any %d of them can reset the master password.\n\n", recoveryShares, recoveryThreshold)
+		for i, share := range shares {
+			fmt.Printf("Share %d:\n  %s\n\n", i+1, shamir.FormatShare(recoveryThreshold, share))
+		}
+		fmt.Println("Give each share to a different person. They will not be shown again.")
+	},
+}
+
+var recoveryCombineCmd = &cobra.Command{
+	Use:   "combine",
+	Short: "Reset the master password from Shamir shares",
+	Long: `Combine enough shares from 'pm recovery split' to reconstruct the recovery
+key, then set a new master password. The recovery key is used up in the
+process; run 'pm recovery split' again to issue new shares.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+		defer store.Wipe()
+		applyKeyFile(store)
+
+		reader := bufio.NewReader(os.Stdin)
+		var shares [][]byte
+		// The threshold is taken from the first share; every other share
+		// must agree with it. entered maps the index of each share, its
+		// first byte, to the number it was entered as.
+		threshold := 0
+		entered := make(map[byte]int)
+		for threshold == 0 || len(shares) < threshold {
+			fmt.Printf("Enter share %d: ", len(shares)+1)
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				fmt.Fprintf(os.Stderr, "Error reading share: %v\n", err)
+				os.Exit(1)
+			}
+
+			shareThreshold, share, err := shamir.ParseShare(strings.TrimSpace(line))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid share: %v\n", err)
+				continue
+			}
+			if threshold != 0 && shareThreshold != threshold {
+				fmt.Fprintf(os.Stderr, "Share %d needs %d shares to recover, but share 1 needs %d.\n", len(shares)+1, shareThreshold, threshold)
+				fmt.Fprintf(os.Stderr, "The shares come from different splits, or one of them is mistyped.\n")
+				os.Exit(1)
+			}
+			if n, ok := entered[share[0]]; ok {
+				crypto.Wipe(share)
+				fmt.Fprintf(os.Stderr, "This share was already entered as share %d. Enter a different share.\n", n)
+				continue
+			}
+			threshold = shareThreshold
+			shares = append(shares, share)
+			entered[share[0]] = len(shares)
+		}
+
+		key, err := shamir.Combine(shares)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error combining shares: %v\n", err)
+			os.Exit(1)
+		}
+
+		vault, err := store.LoadVaultWithSharedRecoveryKey(key)
+		crypto.Wipe(key)
+		for _, share := range shares {
+			crypto.Wipe(share)
+		}
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidPassword) {
+				fmt.Fprintf(os.Stderr, "The shares do not reconstruct a valid recovery key.\n")
+			} else {
+				fmt.Fprintf(os.Stderr, "Error loading vault: %v\n", err)
+			}
+			os.Exit(1)
+		}
+
+		resetMasterPassword(store, vault)
+	},
+}
+
+// printRecoveryCodes shows freshly generated codes with instructions
+func printRecoveryCodes(codes []string) {
+	fmt.Println("\nRecovery codes (each can be used once with 'pm recover'):")
+	fmt.Println()
+	for _, code := range codes {
+		fmt.Printf("  %s\n", code)
+	}
+	fmt.Println("\nStore these codes somewhere safe and offline. They will not be shown again.")
+}
+
+func init() {
+	recoveryGenerateCmd.Flags().IntVar(&recoveryCount, "count", defaultRecoveryCodes, "number of recovery codes to generate")
+	recoverySplitCmd.Flags().IntVar(&recoveryShares, "shares", 5, "number of shares to create")
+	recoverySplitCmd.Flags().IntVar(&recoveryThreshold, "threshold", 3, "number of shares needed to recover")
+}
@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 
+	"passwordmanager/config"
+	"passwordmanager/storage"
+
 	"github.com/spf13/cobra"
 )
 
@@ -12,34 +15,226 @@ var rootCmd = &cobra.Command{
 	Use:   "pm",
 	Short: "Password Manager - A secure command-line password manager",
 	Long: `Password Manager (pm) is a secure command-line tool for managing your passwords.
-It uses AES-256-GCM encryption to protect your sensitive data.
+It uses AES-256-GCM or XChaCha20-Poly1305 encryption to protect your sensitive data.
 
 Features:
 - Add, retrieve, update, and delete password entries
 - Generate secure random passwords
-- Master password protection
+- Master password protection, optionally combined with a key file
 - Encrypted local storage`,
 }
 
+// keyFilePath is the --key-file flag shared by every command
+var keyFilePath string
+
+// vaultDir is the --vault-dir flag shared by every command
+var vaultDir string
+
+// vaultName is the --vault flag shared by every command
+var vaultName string
+
+// resolvedPaths caches the directories resolved for this command
+var resolvedPaths *config.Paths
+
+// loadedConfig caches the configuration file read for this command
+var loadedConfig *config.Config
+
 func Execute() error {
 	return rootCmd.Execute()
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&keyFilePath, "key-file", "", "key file required alongside the master password")
+	rootCmd.PersistentFlags().StringVar(&vaultName, "vault", "", "named vault to use (default: the configured default vault)")
+	rootCmd.PersistentFlags().StringVar(&vaultDir, "vault-dir", "", "data directory holding the vault (default $"+config.EnvVaultDir+" or the XDG data directory)")
+
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(getCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(trashCmd)
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(passwdCmd)
+	rootCmd.AddCommand(keyfileCmd)
+	rootCmd.AddCommand(recoveryCmd)
+	rootCmd.AddCommand(recoverCmd)
+	rootCmd.AddCommand(vaultCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(doctorCmd)
+
+	keyfileCmd.AddCommand(keyfileGenerateCmd)
+	recoveryCmd.AddCommand(recoveryGenerateCmd)
+	recoveryCmd.AddCommand(recoverySplitCmd)
+	recoveryCmd.AddCommand(recoveryCombineCmd)
+	vaultCmd.AddCommand(vaultConvertCmd)
+	vaultCmd.AddCommand(vaultCreateCmd)
+	vaultCmd.AddCommand(vaultListCmd)
+	vaultCmd.AddCommand(vaultDefaultCmd)
+	vaultCmd.AddCommand(vaultCopyCmd)
+	vaultCmd.AddCommand(vaultMoveCmd)
+	vaultCmd.AddCommand(vaultMigrateCmd)
+	trashCmd.AddCommand(trashListCmd)
+	trashCmd.AddCommand(trashRestoreCmd)
+	trashCmd.AddCommand(trashEmptyCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
 }
 
+// getDataDir returns the directory holding the vault
 func getDataDir() string {
-	homeDir, err := os.UserHomeDir()
+	return resolvePaths().DataDir
+}
+
+// getConfigDir returns the directory holding the configuration file
+func getConfigDir() string {
+	return resolvePaths().ConfigDir
+}
+
+// resolvePaths works out the data and config directories from --vault-dir,
+// $PM_VAULT_DIR, the data_dir setting and the XDG base directories, moving
+// a legacy ~/.passwordmanager into place on first use
+func resolvePaths() config.Paths {
+	if resolvedPaths != nil {
+		return *resolvedPaths
+	}
+
+	resolved, err := config.ResolvePaths(vaultDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding data directory: %v\n", err)
+		os.Exit(1)
+	}
+	if resolved.LegacyDir != "" {
+		cfg, err := config.Load(resolved.ConfigDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.DataDir != "" {
+			resolved.DataDir = cfg.DataDir
+		} else {
+			resolved = migrateLegacyDir(resolved)
+		}
+	}
+	resolvedPaths = &resolved
+	return resolved
+}
+
+// migrateLegacyDir moves the legacy data directory to the XDG data
+// directory, and its configuration file to the XDG config directory. If the
+// move fails the legacy directory stays in use.
+func migrateLegacyDir(paths config.Paths) config.Paths {
+	if _, err := os.Stat(paths.DataDir); err == nil {
+		return paths
+	}
+	if _, err := os.Stat(paths.LegacyDir); err != nil {
+		return paths
+	}
+
+	if err := storage.RelocateDataDir(paths.LegacyDir, paths.DataDir); err != nil {
+		if _, statErr := os.Stat(paths.DataDir); statErr == nil {
+			// Another pm process moved it first
+			return paths
+		}
+		fmt.Fprintf(os.Stderr, "Warning: could not move %s to %s: %v\n", paths.LegacyDir, paths.DataDir, err)
+		return config.Paths{DataDir: paths.LegacyDir, ConfigDir: paths.LegacyDir}
+	}
+	fmt.Fprintf(os.Stderr, "Moved data directory from %s to %s\n", paths.LegacyDir, paths.DataDir)
+
+	for _, name := range []string{config.FileName, config.LegacyFileName} {
+		oldConfig := filepath.Join(paths.DataDir, name)
+		if _, err := os.Stat(oldConfig); err != nil {
+			continue
+		}
+		err := os.MkdirAll(paths.ConfigDir, 0700)
+		if err == nil {
+			err = os.Rename(oldConfig, filepath.Join(paths.ConfigDir, name))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error moving configuration file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	return paths
+}
+
+// loadConfig returns the configuration file, read once per command. On
+// failure, such as an unknown key, the process exits.
+func loadConfig() *config.Config {
+	if loadedConfig != nil {
+		return loadedConfig
+	}
+
+	cfg, err := config.Load(getConfigDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+	loadedConfig = cfg
+	return cfg
+}
+
+// currentOptions returns the configured options of the current vault
+func currentOptions() config.Options {
+	return loadConfig().Options(currentVault())
+}
+
+// currentVault returns the name of the vault selected with --vault, or the
+// configured default vault
+func currentVault() string {
+	if vaultName != "" {
+		return vaultName
+	}
+	if cfg := loadConfig(); cfg.DefaultVault != "" {
+		return cfg.DefaultVault
+	}
+	return storage.DefaultVaultName
+}
+
+// openStore opens the storage of the current vault. A named vault that has
+// not been created is an error.
+func openStore() *storage.Storage {
+	name := currentVault()
+	if name != storage.DefaultVaultName {
+		if _, err := os.Stat(storage.VaultDir(getDataDir(), name)); err != nil {
+			fmt.Fprintf(os.Stderr, "Vault '%s' not found. Create it with 'pm vault create %s'.\n", name, name)
+			os.Exit(1)
+		}
+	}
+	return openVault(name)
+}
+
+// openVault opens the storage of the named vault, using the backend and
+// options from the configuration file
+func openVault(name string) *storage.Storage {
+	if err := storage.ValidateVaultName(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	dir := storage.VaultDir(getDataDir(), name)
+	backend, err := storage.OpenBackend(cfg.Backend, dir)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting home directory: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error opening storage: %v\n", err)
 		os.Exit(1)
 	}
-	return filepath.Join(homeDir, ".passwordmanager")
+
+	opts := cfg.Options(name)
+	store := storage.NewStorageWithBackend(backend, dir)
+	store.SetBackupPolicy(storage.BackupPolicy{Keep: opts.BackupKeep, MaxAge: opts.BackupMaxAge})
+	store.SetLockTimeout(opts.LockTimeout)
+	store.SetTrashRetention(opts.TrashRetention)
+	return store
 }
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+	"passwordmanager/models"
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// maxSuggestions is how many close matches findEntry offers for an entry
+// that was not found
+const maxSuggestions = 10
+
+var searchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search password entries",
+	Long: `Search the titles, usernames, URLs, notes and tags of the password entries,
+best match first. Matching ignores case and is fuzzy: "gthb" finds "GitHub".
+Every word of the query must match. Scope a word to one field with title:,
+user:, url:, notes: or tag:, as in
+
+  pm search url:github user:alice
+  pm search tag:work
+
+Passwords are never searched.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := strings.Join(args, " ")
+
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		results := storage.Search(vault, storage.ParseQuery(query))
+		if len(results) == 0 {
+			fmt.Printf("No password entries match '%s'.\n", query)
+			return
+		}
+
+		fmt.Printf("%-8s  %-30s %-25s %s\n", "ID", "Title", "Username", "URL")
+		for _, result := range results {
+			entry := vault.Entries[result.Index]
+			fmt.Printf("%-8s  %-30s %-25s %s\n", storage.ShortID(entry.ID), storage.EntryPath(entry), entry.Username, entry.URL)
+		}
+	},
+}
+
+// findEntry returns the index of the entry ref names, by ID, title,
+// folder/title path or unique ID prefix, or -1. When ref matches several entries the user picks
+// one, see pickEntry. When it matches none, a user at a terminal may pick
+// one of the closest search results instead.
+func findEntry(vault *models.PasswordVault, ref string) int {
+	index, err := storage.FindEntry(vault, ref)
+	if err == nil {
+		return index
+	}
+
+	var ambiguous *storage.AmbiguousEntryError
+	if errors.As(err, &ambiguous) {
+		return pickEntry(vault.Entries, ambiguous.Matches, fmt.Sprintf("More than one entry matches '%s'.", ref))
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return -1
+	}
+	var suggestions []int
+	for _, result := range storage.Search(vault, storage.ParseQuery(ref)) {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, result.Index)
+	}
+	if len(suggestions) == 0 {
+		return -1
+	}
+	return pickEntry(vault.Entries, suggestions, fmt.Sprintf("Password entry '%s' not found. Did you mean:", ref))
+}
+
+// pickEntry lists the candidates, indexes in entries, under heading and
+// lets the user choose one by number. Without a terminal to ask at it
+// prints their IDs and exits.
+func pickEntry(entries []models.PasswordEntry, candidates []int, heading string) int {
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	out := os.Stdout
+	if !interactive {
+		out = os.Stderr
+	}
+
+	fmt.Fprintln(out, heading)
+	for n, i := range candidates {
+		entry := entries[i]
+		fmt.Fprintf(out, "  %d. %s  %s (%s)\n", n+1, storage.ShortID(entry.ID), storage.EntryPath(entry), entry.Username)
+	}
+	if !interactive {
+		fmt.Fprintln(out, "Use one of their IDs instead.")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Select an entry [1-%d] (Enter to cancel): ", len(candidates))
+	var choice string
+	fmt.Scanln(&choice)
+	if choice == "" {
+		fmt.Println("No entry selected.")
+		os.Exit(1)
+	}
+	n, err := strconv.Atoi(choice)
+	if err != nil || n < 1 || n > len(candidates) {
+		fmt.Fprintf(os.Stderr, "Invalid selection: %s\n", choice)
+		os.Exit(1)
+	}
+	return candidates[n-1]
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"passwordmanager/models"
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage deleted password entries",
+	Long: `Deleted entries are kept in the trash until they are restored, the trash is
+emptied, or they are older than the trash_retention setting. Purged entries
+remain in the vault backups until those are pruned.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the entries in the trash",
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		if len(vault.Trash) == 0 {
+			fmt.Println("The trash is empty.")
+			return
+		}
+
+		retention := currentOptions().TrashRetention
+		fmt.Printf("%-8s  %-30s %-25s %-20s %s\n", "ID", "Title", "Username", "Deleted", "Purged")
+		for _, trashed := range vault.Trash {
+			purged := "when emptied"
+			if expiry := storage.TrashExpiry(trashed, retention); !expiry.IsZero() {
+				purged = expiry.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%-8s  %-30s %-25s %-20s %s\n", storage.ShortID(trashed.ID), storage.EntryPath(trashed.PasswordEntry), trashed.Username, trashed.DeletedAt.Format("2006-01-02 15:04:05"), purged)
+		}
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore [title|path|id]",
+	Short: "Restore a deleted password entry",
+	Long: `Move a password entry from the trash back into the vault. The entry is
+named by title, folder/title path, ID or ID prefix, as listed by 'pm trash
+list'. If several deleted entries match, you choose which one to restore.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ref := args[0]
+
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		index := findTrashed(vault, ref)
+		if index < 0 {
+			fmt.Printf("Password entry '%s' not found in the trash.\n", ref)
+			return
+		}
+		trashed := vault.Trash[index]
+		path := storage.EntryPath(trashed.PasswordEntry)
+		if storage.TitleInUse(vault, trashed.Folder, trashed.Title) {
+			fmt.Fprintf(os.Stderr, "Password entry '%s' already exists. Delete it first.\n", path)
+			os.Exit(1)
+		}
+
+		storage.RestoreTrashed(vault, index)
+		if err := store.SaveVault(vault, masterPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Password entry '%s' restored from the trash.\n", path)
+	},
+}
+
+// findTrashed returns the index in vault.Trash of the entry ref names, or -1
+// if there is none. When ref matches several, the user picks one, see
+// pickEntry.
+func findTrashed(vault *models.PasswordVault, ref string) int {
+	index, err := storage.FindTrashed(vault, ref)
+	if err == nil {
+		return index
+	}
+
+	var ambiguous *storage.AmbiguousEntryError
+	if errors.As(err, &ambiguous) {
+		return pickEntry(storage.TrashedEntries(vault), ambiguous.Matches, fmt.Sprintf("More than one deleted entry matches '%s'.", ref))
+	}
+	return -1
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Permanently remove every entry in the trash",
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		if len(vault.Trash) == 0 {
+			fmt.Println("The trash is empty.")
+			return
+		}
+
+		fmt.Printf("Permanently remove %d entries from the trash? (y/N): ", len(vault.Trash))
+		var confirm string
+		fmt.Scanln(&confirm)
+		if confirm != "y" && confirm != "Y" {
+			fmt.Println("Trash not emptied.")
+			return
+		}
+
+		count := len(vault.Trash)
+		vault.Trash = nil
+		if err := store.SaveVault(vault, masterPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Removed %d entries from the trash.\n", count)
+	},
+}
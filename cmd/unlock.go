@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/term"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"passwordmanager/storage"
+)
+
+// unlockVault prompts for the master password and opens the vault with it.
+// A wrong password is detected by the vault failing to authenticate. On
+// any failure the process exits. The caller must Wipe the returned password.
+func unlockVault(store *storage.Storage) (*models.PasswordVault, *crypto.Secret) {
+	return unlockVaultWith(store, "Enter master password: ", keyFilePath)
+}
+
+// unlockVaultWith is unlockVault with its own prompt and key file, for
+// commands that open more than one vault
+func unlockVaultWith(store *storage.Storage, prompt, keyFile string) (*models.PasswordVault, *crypto.Secret) {
+	setKeyFile(store, keyFile)
+
+	masterPassword := readSecret(prompt)
+
+	if err := store.UpgradeUser(masterPassword); err != nil {
+		exitUnlockError("Error upgrading user configuration", err)
+	}
+
+	// LoadVault completes an interrupted save first, which may bring back a
+	// missing vault.dat
+	vault, err := store.LoadVault(masterPassword)
+	if err != nil {
+		exitUnlockError("Error loading vault", err)
+	}
+
+	if !store.VaultExists() {
+		fmt.Fprintf(os.Stderr, "Vault file not found. Restore vault.dat from a backup.\n")
+		os.Exit(1)
+	}
+
+	return vault, masterPassword
+}
+
+// lockStore takes the vault lock for the rest of the command, so that no
+// other pm process saves between this one loading and saving the vault. The
+// caller must defer store.Unlock.
+func lockStore(store *storage.Storage) {
+	if err := store.Lock(); err != nil {
+		exitUnlockError("Error locking vault", err)
+	}
+}
+
+// readSecret prompts for a secret without echoing it and moves it straight
+// into a Secret. The caller must Wipe it.
+func readSecret(prompt string) *crypto.Secret {
+	fmt.Print(prompt)
+	input, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println()
+
+	return crypto.NewSecret(input)
+}
+
+// maxVisibleSecret is the longest line readVisibleSecret accepts, well
+// above the length of a recovery code
+const maxVisibleSecret = 256
+
+// readVisibleSecret prompts for a secret that is shown as it is typed, such
+// as a recovery code. It reads stdin a byte at a time into a fixed buffer,
+// so no copy of the line is left behind in a reader's buffer or a string.
+func readVisibleSecret(prompt string) *crypto.Secret {
+	fmt.Print(prompt)
+	line := make([]byte, 0, maxVisibleSecret)
+	var c [1]byte
+	for {
+		n, err := os.Stdin.Read(c[:])
+		if n == 1 && c[0] != '\n' {
+			if len(line) == cap(line) {
+				crypto.Wipe(line)
+				fmt.Fprintf(os.Stderr, "Input too long.\n")
+				os.Exit(1)
+			}
+			line = append(line, c[0])
+			continue
+		}
+		if n == 1 || err != nil {
+			break
+		}
+	}
+	c[0] = 0
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line[len(line)-1] = 0
+		line = line[:len(line)-1]
+	}
+	return crypto.NewSecret(line)
+}
+
+// readNewPassword prompts for a new master password twice, naming it with
+// label, and exits if the entries differ or are empty. The caller must Wipe
+// the returned password.
+func readNewPassword(label string) *crypto.Secret {
+	newPassword := readSecret(fmt.Sprintf("Enter %s: ", label))
+	confirmPassword := readSecret(fmt.Sprintf("Confirm %s: ", label))
+	match := newPassword.Equal(confirmPassword)
+	confirmPassword.Wipe()
+
+	if !match {
+		fmt.Fprintf(os.Stderr, "Passwords do not match.\n")
+		os.Exit(1)
+	}
+
+	if newPassword.Len() == 0 {
+		fmt.Fprintf(os.Stderr, "Master password cannot be empty.\n")
+		os.Exit(1)
+	}
+
+	return newPassword
+}
+
+// applyKeyFile reads the file named by --key-file, if any, into the store
+func applyKeyFile(store *storage.Storage) {
+	setKeyFile(store, keyFilePath)
+}
+
+// setKeyFile reads the key file at path, if any, into the store
+func setKeyFile(store *storage.Storage, path string) {
+	if path == "" {
+		return
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading key file: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = store.SetKeyFile(contents)
+	crypto.Wipe(contents)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading key file %s: %v\n", path, err)
+		os.Exit(1)
+	}
+}
+
+// exitUnlockError reports a failed unlock and exits
+func exitUnlockError(context string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrInvalidPassword) && keyFilePath != "":
+		fmt.Fprintf(os.Stderr, "Invalid master password or key file.\n")
+	case errors.Is(err, storage.ErrInvalidPassword):
+		fmt.Fprintf(os.Stderr, "Invalid master password.\n")
+	case errors.Is(err, storage.ErrKeyFileRequired):
+		fmt.Fprintf(os.Stderr, "This vault requires a key file. Pass it with --key-file.\n")
+	case errors.Is(err, storage.ErrKeyFileNotUsed):
+		fmt.Fprintf(os.Stderr, "This vault is not protected by a key file. Run the command without --key-file.\n")
+	case errors.Is(err, storage.ErrVaultBusy):
+		fmt.Fprintf(os.Stderr, "The %v. Try again once the other pm command has finished.\n", err)
+	case errors.Is(err, storage.ErrVaultMismatch):
+		fmt.Fprintf(os.Stderr, "vault.dat does not belong to this password manager. It may have been replaced.\n")
+	case errors.Is(err, storage.ErrVaultRollback):
+		fmt.Fprintf(os.Stderr, "vault.dat is older than the last saved vault. It may have been replaced with an old copy.\n")
+	case errors.Is(err, storage.ErrVaultTooNew):
+		fmt.Fprintf(os.Stderr, "The %v. Upgrade pm to open it.\n", err)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", context, err)
+	}
+	os.Exit(1)
+}
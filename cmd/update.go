@@ -1,115 +1,123 @@
 package cmd
 
 import (
-	"crypto/subtle"
 	"fmt"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
 	"golang.org/x/term"
 	"passwordmanager/crypto"
+	"passwordmanager/models"
 	"passwordmanager/storage"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	updateFolder string
+	updateTags   []string
+)
+
 var updateCmd = &cobra.Command{
-	Use:   "update [title]",
+	Use:   "update [title|folder/title|id]",
 	Short: "Update a password entry",
-	Long:  `Update an existing password entry by title.`,
+	Long: `Update an existing password entry by title, folder/title path, ID or unique
+ID prefix. The version it replaces is kept in the entry's history; see
+'pm history'. --folder moves the entry to another folder, or to the top level
+when empty. --tag replaces the entry's tags; --tag "" removes them.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		title := args[0]
 		
-		dataDir := getDataDir()
-		store := storage.NewStorage(dataDir)
+		store := openStore()
 		
 		if !store.UserExists() {
 			fmt.Println("Password manager not initialized. Run 'pm init' first.")
 			return
 		}
 
-		user, err := store.LoadUser()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading user: %v\n", err)
-			os.Exit(1)
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		index := findEntry(vault, title)
+		if index < 0 {
+			fmt.Printf("Password entry '%s' not found.\n", title)
+			return
 		}
+		entry := vault.Entries[index]
 
-		fmt.Print("Enter master password: ")
-		masterPassword, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
-			os.Exit(1)
+		if cmd.Flags().Changed("folder") {
+			folder := storage.CleanFolder(updateFolder)
+			if folder != entry.Folder && storage.TitleInUse(vault, folder, entry.Title) {
+				fmt.Fprintf(os.Stderr, "Password entry '%s' already exists.\n", storage.EntryPath(models.PasswordEntry{Folder: folder, Title: entry.Title}))
+				os.Exit(1)
+			}
+			entry.Folder = folder
+		}
+		if cmd.Flags().Changed("tag") {
+			entry.Tags = storage.CleanTags(updateTags)
+		}
+
+		fmt.Printf("Current entry:\n")
+		fmt.Printf("Title: %s\n", entry.Title)
+		if entry.Folder != "" {
+			fmt.Printf("Folder: %s\n", entry.Folder)
+		}
+		if len(entry.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(entry.Tags, ", "))
 		}
+		fmt.Printf("Username: %s\n", entry.Username)
+		fmt.Printf("URL: %s\n", entry.URL)
+		fmt.Printf("Notes: %s\n", entry.Notes)
 		fmt.Println()
 
-		computedHash := crypto.HashPassword(string(masterPassword), user.Salt)
-		if subtle.ConstantTimeCompare([]byte(computedHash), []byte(user.MasterPasswordHash)) != 1 {
-			fmt.Fprintf(os.Stderr, "Invalid master password.\n")
-			os.Exit(1)
+		fmt.Print("Enter new username (press Enter to keep current): ")
+		var newUsername string
+		fmt.Scanln(&newUsername)
+		if newUsername != "" {
+			entry.Username = newUsername
 		}
 
-		vault, err := store.LoadVault(string(masterPassword))
+		fmt.Print("Enter new password (press Enter to keep current): ")
+		newPassword, err := term.ReadPassword(int(syscall.Stdin))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading vault: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Println()
+		if len(newPassword) > 0 {
+			entry.Password = string(newPassword)
+		}
+		crypto.Wipe(newPassword)
 
-		for i, entry := range vault.Entries {
-			if entry.Title == title {
-				fmt.Printf("Current entry:\n")
-				fmt.Printf("Title: %s\n", entry.Title)
-				fmt.Printf("Username: %s\n", entry.Username)
-				fmt.Printf("URL: %s\n", entry.URL)
-				fmt.Printf("Notes: %s\n", entry.Notes)
-				fmt.Println()
-
-				fmt.Print("Enter new username (press Enter to keep current): ")
-				var newUsername string
-				fmt.Scanln(&newUsername)
-				if newUsername != "" {
-					entry.Username = newUsername
-				}
-
-				fmt.Print("Enter new password (press Enter to keep current): ")
-				newPassword, err := term.ReadPassword(int(syscall.Stdin))
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
-					os.Exit(1)
-				}
-				fmt.Println()
-				if len(newPassword) > 0 {
-					entry.Password = string(newPassword)
-				}
-
-				fmt.Print("Enter new URL (press Enter to keep current): ")
-				var newURL string
-				fmt.Scanln(&newURL)
-				if newURL != "" {
-					entry.URL = newURL
-				}
-
-				fmt.Print("Enter new notes (press Enter to keep current): ")
-				var newNotes string
-				fmt.Scanln(&newNotes)
-				if newNotes != "" {
-					entry.Notes = newNotes
-				}
-
-				entry.UpdatedAt = time.Now()
-				vault.Entries[i] = entry
-
-				if err := store.SaveVault(vault, string(masterPassword)); err != nil {
-					fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
-					os.Exit(1)
-				}
-
-				fmt.Printf("Password entry '%s' updated successfully!\n", title)
-				return
-			}
+		fmt.Print("Enter new URL (press Enter to keep current): ")
+		var newURL string
+		fmt.Scanln(&newURL)
+		if newURL != "" {
+			entry.URL = newURL
+		}
+
+		fmt.Print("Enter new notes (press Enter to keep current): ")
+		var newNotes string
+		fmt.Scanln(&newNotes)
+		if newNotes != "" {
+			entry.Notes = newNotes
 		}
 
-		fmt.Printf("Password entry '%s' not found.\n", title)
+		entry.UpdatedAt = time.Now()
+		before := vault.Entries[index]
+		storage.RecordRevision(&entry, before, currentOptions().HistoryKeep)
+		vault.Entries[index] = entry
+		saveEntryChange(store, vault, storage.EntryChange{Before: &before, After: &entry}, masterPassword)
+
+		fmt.Printf("Password entry '%s' updated successfully!\n", storage.EntryPath(entry))
 	},
 }
+
+func init() {
+	updateCmd.Flags().StringVar(&updateFolder, "folder", "", "move the entry to this folder, empty for the top level")
+	updateCmd.Flags().StringSliceVar(&updateTags, "tag", nil, "replace the entry's tags; may be repeated")
+}
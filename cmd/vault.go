@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"passwordmanager/crypto"
+	"passwordmanager/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	vaultConvertCipher string
+	vaultToKeyFile     string
+	vaultMigrateDryRun bool
+)
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Manage vaults",
+	Long: `Create, list, convert and migrate vaults, and copy or move entries
+between them.
+
+Each named vault has its own master password and files. Commands use the
+vault given with --vault, or the default vault.`,
+}
+
+var vaultCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a named vault",
+	Long: `Create a new named vault with its own master password. It accepts the
+same encryption options as 'pm init'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		codes, ok := initializeVault(openVault(name))
+		if !ok {
+			fmt.Printf("Vault '%s' already exists.\n", name)
+			return
+		}
+
+		fmt.Printf("Vault '%s' created. Use it with --vault %s.\n", name, name)
+		if len(codes) > 0 {
+			printRecoveryCodes(codes)
+		}
+	},
+}
+
+var vaultListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List vaults",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := storage.ListVaults(getDataDir())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing vaults: %v\n", err)
+			os.Exit(1)
+		}
+
+		current := currentVault()
+		for _, name := range names {
+			marker := " "
+			if name == current {
+				marker = "*"
+			}
+			status := ""
+			if !openVault(name).UserExists() {
+				status = " (not initialized)"
+			}
+			fmt.Printf("%s %s%s\n", marker, name, status)
+		}
+	},
+}
+
+var vaultDefaultCmd = &cobra.Command{
+	Use:   "default [name]",
+	Short: "Show or set the default vault",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+		if len(args) == 0 {
+			name := cfg.DefaultVault
+			if name == "" {
+				name = storage.DefaultVaultName
+			}
+			fmt.Println(name)
+			return
+		}
+
+		name := args[0]
+		if !openVault(name).UserExists() {
+			fmt.Fprintf(os.Stderr, "Vault '%s' not found. Create it with 'pm vault create %s'.\n", name, name)
+			os.Exit(1)
+		}
+
+		cfg.DefaultVault = name
+		if name == storage.DefaultVaultName {
+			cfg.DefaultVault = ""
+		}
+		if err := cfg.Save(getConfigDir()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Default vault set to '%s'.\n", name)
+	},
+}
+
+var vaultCopyCmd = &cobra.Command{
+	Use:   "copy [title] [vault]",
+	Short: "Copy an entry to another vault",
+	Long: `Copy a password entry from the current vault to another vault. Both
+master passwords are asked for; pass --to-key-file if the other vault
+requires a key file.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		transferEntry(args[0], args[1], false)
+	},
+}
+
+var vaultMoveCmd = &cobra.Command{
+	Use:   "move [title] [vault]",
+	Short: "Move an entry to another vault",
+	Long: `Move a password entry from the current vault to another vault. The
+entry is only removed once it has been saved in the other vault.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		transferEntry(args[0], args[1], true)
+	},
+}
+
+var vaultConvertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Re-encrypt the vault with a different cipher",
+	Long: `Re-encrypt the vault contents with a different cipher suite.
+
+Supported ciphers are aes-256-gcm and xchacha20-poly1305. Unlock methods
+(master password, key file, recovery codes) keep working unchanged.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		suite, err := crypto.ParseCipherSuite(vaultConvertCipher)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid cipher: %v\n", err)
+			os.Exit(1)
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		if store.CipherSuite() == suite {
+			fmt.Printf("Vault is already encrypted with %s.\n", suite)
+			return
+		}
+		previous := store.CipherSuite()
+
+		if err := store.SetCipherSuite(suite); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid cipher: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := store.SaveVault(vault, masterPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Vault converted from %s to %s.\n", previous, suite)
+	},
+}
+
+var vaultMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the vault to the current schema version",
+	Long: `Upgrade the vault contents to the schema version of this version of pm.
+
+A vault written by an older version is upgraded in memory whenever it is
+opened, and saved in the new version by the next change. This command saves
+it straight away; with --dry-run it only lists the migrations that apply.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openStore()
+
+		if !store.UserExists() {
+			fmt.Println("Password manager not initialized. Run 'pm init' first.")
+			return
+		}
+
+		lockStore(store)
+		defer store.Unlock()
+
+		vault, masterPassword := unlockVault(store)
+		defer store.Wipe()
+		defer masterPassword.Wipe()
+
+		migrations := store.PendingMigrations()
+		if len(migrations) == 0 {
+			fmt.Printf("Vault is up to date (version %s).\n", storage.CurrentVaultVersion)
+			return
+		}
+
+		fmt.Printf("Vault version %s, current version %s:\n", migrations[0].From, storage.CurrentVaultVersion)
+		for _, m := range migrations {
+			fmt.Printf("  %s -> %s: %s\n", m.From, m.To, m.Description)
+		}
+
+		if vaultMigrateDryRun {
+			fmt.Println("Dry run: the vault was not changed.")
+			return
+		}
+
+		if err := store.SaveVault(vault, masterPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Vault migrated to version %s.\n", storage.CurrentVaultVersion)
+	},
+}
+
+func init() {
+	vaultConvertCmd.Flags().StringVar(&vaultConvertCipher, "cipher", "", "cipher to convert to (aes-256-gcm or xchacha20-poly1305)")
+	vaultConvertCmd.MarkFlagRequired("cipher")
+	addInitFlags(vaultCreateCmd)
+	vaultMigrateCmd.Flags().BoolVar(&vaultMigrateDryRun, "dry-run", false, "only list the migrations that would be applied")
+	vaultCopyCmd.Flags().StringVar(&vaultToKeyFile, "to-key-file", "", "key file of the vault the entry is copied to")
+	vaultMoveCmd.Flags().StringVar(&vaultToKeyFile, "to-key-file", "", "key file of the vault the entry is moved to")
+}
+
+// transferEntry copies, or with move moves, the entry titled title from the
+// current vault to the vault named target
+func transferEntry(title, target string, move bool) {
+	source := currentVault()
+	if target == source {
+		fmt.Fprintf(os.Stderr, "The entry is already in vault '%s'.\n", source)
+		os.Exit(1)
+	}
+
+	sourceStore := openStore()
+	if !sourceStore.UserExists() {
+		fmt.Println("Password manager not initialized. Run 'pm init' first.")
+		return
+	}
+	targetStore := openVault(target)
+	if !targetStore.UserExists() {
+		fmt.Fprintf(os.Stderr, "Vault '%s' not found. Create it with 'pm vault create %s'.\n", target, target)
+		os.Exit(1)
+	}
+
+	sourceVault, sourcePassword := unlockVaultWith(sourceStore, fmt.Sprintf("Enter master password for vault '%s': ", source), keyFilePath)
+	defer sourceStore.Wipe()
+	defer sourcePassword.Wipe()
+
+	index := findEntry(sourceVault, title)
+	if index < 0 {
+		fmt.Printf("Password entry '%s' not found.\n", title)
+		return
+	}
+	entry := sourceVault.Entries[index]
+
+	targetVault, targetPassword := unlockVaultWith(targetStore, fmt.Sprintf("Enter master password for vault '%s': ", target), vaultToKeyFile)
+	defer targetStore.Wipe()
+	defer targetPassword.Wipe()
+
+	if storage.TitleInUse(targetVault, entry.Folder, entry.Title) {
+		fmt.Fprintf(os.Stderr, "Vault '%s' already has an entry '%s'.\n", target, entry.Title)
+		os.Exit(1)
+	}
+
+	copied := entry
+	if !move {
+		id, err := storage.NewEntryID()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		copied.ID = id
+	}
+	targetVault.Entries = append(targetVault.Entries, copied)
+	saveEntryChange(targetStore, targetVault, storage.EntryChange{After: &copied}, targetPassword)
+
+	if !move {
+		fmt.Printf("Password entry '%s' copied to vault '%s'.\n", entry.Title, target)
+		return
+	}
+
+	sourceVault.Entries = append(sourceVault.Entries[:index], sourceVault.Entries[index+1:]...)
+	saveEntryChange(sourceStore, sourceVault, storage.EntryChange{Before: &entry}, sourcePassword)
+	fmt.Printf("Password entry '%s' moved to vault '%s'.\n", entry.Title, target)
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileName is the configuration file kept in the config directory
+const FileName = "config.toml"
+
+// LegacyFileName is the JSON configuration file written by earlier
+// versions. It is read when FileName does not exist and replaced by the
+// next Save.
+const LegacyFileName = "config.json"
+
+// Config holds the settings read from the configuration file. Unset fields
+// use their defaults, see Options.
+type Config struct {
+	// DataDir replaces the XDG data directory; --vault-dir and
+	// $PM_VAULT_DIR still take precedence
+	DataDir string `toml:"data_dir,omitempty"`
+
+	// Backend selects the storage backend, see storage.OpenBackend
+	Backend string `toml:"backend,omitempty"`
+
+	// DefaultVault names the vault used when --vault is not given
+	DefaultVault string `toml:"default_vault,omitempty"`
+
+	// Settings apply to every vault unless overridden in Vaults
+	Settings
+
+	// Vaults holds per-vault overrides, keyed by vault name
+	Vaults map[string]Settings `toml:"vaults,omitempty"`
+}
+
+// Settings are the options that can be set for all vaults and overridden
+// for one. A nil field is unset.
+type Settings struct {
+	ClipboardTimeout *Duration `toml:"clipboard_timeout,omitempty"`
+	GenerateLength   *int      `toml:"generate_length,omitempty"`
+	BackupKeep       *int      `toml:"backup_keep,omitempty"`
+	BackupMaxAge     *Duration `toml:"backup_max_age,omitempty"`
+	LockTimeout      *Duration `toml:"lock_timeout,omitempty"`
+	HistoryKeep      *int      `toml:"history_keep,omitempty"`
+	TrashRetention   *Duration `toml:"trash_retention,omitempty"`
+}
+
+// isZero reports whether no setting is set
+func (s Settings) isZero() bool {
+	return s == Settings{}
+}
+
+// Duration is a time.Duration written as a string such as "30s" or "720h"
+type Duration struct {
+	time.Duration
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, use a value such as 30s or 720h", text)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Load reads the configuration file in dir. A missing file yields the
+// default configuration. Unknown keys and invalid values are errors.
+func Load(dir string) (*Config, error) {
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return loadLegacy(dir)
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	meta, err := toml.Decode(string(data), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%s: %w", path, unknownKeyError(undecoded[0].String()))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// loadLegacy reads the JSON configuration file of earlier versions, if any
+func loadLegacy(dir string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Join(dir, LegacyFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var legacy struct {
+		Backend      string `json:"backend"`
+		DefaultVault string `json:"default_vault"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return &Config{Backend: legacy.Backend, DefaultVault: legacy.DefaultVault}, nil
+}
+
+// Save writes the configuration file in dir, creating dir if needed, and
+// removes a configuration file left by earlier versions
+func (c *Config) Save(dir string) error {
+	for name, overrides := range c.Vaults {
+		if overrides.isZero() {
+			delete(c.Vaults, name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(c); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(dir, LegacyFileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Validate checks every value set in the configuration
+func (c *Config) Validate() error {
+	for _, key := range c.SetKeys() {
+		value, _, _ := c.Get(key)
+		if err := c.clone().Set(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of c that can be changed without affecting c
+func (c *Config) clone() *Config {
+	copied := *c
+	copied.Vaults = make(map[string]Settings, len(c.Vaults))
+	for name, overrides := range c.Vaults {
+		copied.Vaults[name] = overrides
+	}
+	return &copied
+}
+
+// SetKeys returns the keys that have a value: global keys first, then the
+// per-vault overrides sorted by vault
+func (c *Config) SetKeys() []string {
+	var keys []string
+	for _, s := range settings {
+		if _, ok := s.get(c, &c.Settings); ok {
+			keys = append(keys, s.Name)
+		}
+	}
+
+	names := make([]string, 0, len(c.Vaults))
+	for name := range c.Vaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vault := c.Vaults[name]
+		for _, s := range settings {
+			if !s.PerVault {
+				continue
+			}
+			if _, ok := s.get(c, &vault); ok {
+				keys = append(keys, vaultKey(name, s.Name))
+			}
+		}
+	}
+	return keys
+}
+
+// vaultKey returns the key of a per-vault override
+func vaultKey(vault, name string) string {
+	return "vaults." + vault + "." + name
+}
+
+// splitKey splits a key into the vault it overrides, if any, and the
+// setting it names
+func splitKey(key string) (vault, name string) {
+	if rest, ok := strings.CutPrefix(key, "vaults."); ok {
+		if i := strings.LastIndex(rest, "."); i > 0 {
+			return rest[:i], rest[i+1:]
+		}
+	}
+	return "", key
+}
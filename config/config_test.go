@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Backend != "" {
+		t.Errorf("Expected the default backend, got %q", cfg.Backend)
+	}
+}
+
+func TestSave_Load(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+
+	if err := (&Config{Backend: "kv"}).Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Config file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Backend != "kv" {
+		t.Errorf("Expected backend kv, got %q", cfg.Backend)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, FileName), []byte("{"), 0600)
+
+	if _, err := Load(dir); err == nil {
+		t.Error("Load should fail on a malformed config file")
+	}
+}
+
+func TestLoad_UnknownKey(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, FileName), []byte("backend = \"kv\"\nbackup_kep = 3\n"), 0600)
+
+	_, err := Load(dir)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Expected ErrUnknownKey, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `did you mean "backup_keep"`) {
+		t.Errorf("Expected a suggestion, got %v", err)
+	}
+}
+
+func TestLoad_InvalidValue(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, FileName), []byte("[vaults.work]\ngenerate_length = 1000\n"), 0600)
+
+	if _, err := Load(dir); err == nil {
+		t.Error("Load should reject an out of range value")
+	}
+}
+
+func TestSave_Load_PerVault(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{}
+	cfg.Set("clipboard_timeout", "20s")
+	cfg.Set("vaults.work.clipboard_timeout", "5s")
+	cfg.Set("vaults.empty.backup_keep", "3")
+	cfg.Unset("vaults.empty.backup_keep")
+
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := loaded.Options("work").ClipboardTimeout; got != 5*time.Second {
+		t.Errorf("Expected 5s for work, got %s", got)
+	}
+	if _, ok := loaded.Vaults["empty"]; ok {
+		t.Error("Empty vault overrides should not be saved")
+	}
+}
+
+func TestLoad_Legacy(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, LegacyFileName), []byte(`{"backend":"kv","default_vault":"work"}`), 0600)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Backend != "kv" || cfg.DefaultVault != "work" {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LegacyFileName)); !os.IsNotExist(err) {
+		t.Error("Save should remove the legacy config file")
+	}
+}
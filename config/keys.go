@@ -0,0 +1,353 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"passwordmanager/storage"
+)
+
+// ErrUnknownKey is returned for a configuration key that does not exist
+var ErrUnknownKey = errors.New("unknown config key")
+
+// Options are the settings in effect for one vault
+type Options struct {
+	ClipboardTimeout time.Duration
+	GenerateLength   int
+	BackupKeep       int
+	BackupMaxAge     time.Duration
+	LockTimeout      time.Duration
+	HistoryKeep      int
+	TrashRetention   time.Duration
+}
+
+// DefaultOptions returns the options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		ClipboardTimeout: 10 * time.Second,
+		GenerateLength:   16,
+		BackupKeep:       storage.DefaultBackupKeep,
+		LockTimeout:      storage.DefaultLockTimeout,
+		HistoryKeep:      storage.DefaultHistoryKeep,
+		TrashRetention:   storage.DefaultTrashRetention,
+	}
+}
+
+// Options returns the options in effect for the named vault: its overrides,
+// then the settings for all vaults, then the defaults
+func (c *Config) Options(vault string) Options {
+	opts := DefaultOptions()
+	opts.apply(c.Settings)
+	if overrides, ok := c.Vaults[vault]; ok {
+		opts.apply(overrides)
+	}
+	return opts
+}
+
+// apply overwrites the options set in s
+func (o *Options) apply(s Settings) {
+	if s.ClipboardTimeout != nil {
+		o.ClipboardTimeout = s.ClipboardTimeout.Duration
+	}
+	if s.GenerateLength != nil {
+		o.GenerateLength = *s.GenerateLength
+	}
+	if s.BackupKeep != nil {
+		o.BackupKeep = *s.BackupKeep
+	}
+	if s.BackupMaxAge != nil {
+		o.BackupMaxAge = s.BackupMaxAge.Duration
+	}
+	if s.LockTimeout != nil {
+		o.LockTimeout = s.LockTimeout.Duration
+	}
+	if s.HistoryKeep != nil {
+		o.HistoryKeep = *s.HistoryKeep
+	}
+	if s.TrashRetention != nil {
+		o.TrashRetention = s.TrashRetention.Duration
+	}
+}
+
+// Key describes a configuration key
+type Key struct {
+	Name     string
+	Help     string
+	Default  string
+	PerVault bool
+}
+
+// setting is a configuration key and how to read and write it. Global keys
+// live on Config, per-vault keys on the Settings passed along.
+type setting struct {
+	Key
+	get   func(c *Config, s *Settings) (string, bool)
+	set   func(c *Config, s *Settings, value string) error
+	unset func(c *Config, s *Settings)
+}
+
+var defaults = DefaultOptions()
+
+var settings = []setting{
+	stringSetting(Key{Name: "data_dir", Help: "directory holding the vaults (absolute path)"},
+		func(c *Config) *string { return &c.DataDir }, checkDataDir),
+	stringSetting(Key{Name: "backend", Help: "storage backend, file or kv", Default: storage.BackendFile},
+		func(c *Config) *string { return &c.Backend }, checkBackend),
+	stringSetting(Key{Name: "default_vault", Help: "vault used when --vault is not given", Default: storage.DefaultVaultName},
+		func(c *Config) *string { return &c.DefaultVault }, storage.ValidateVaultName),
+	durationSetting(Key{Name: "clipboard_timeout", Help: "how long pm get leaves a password on the clipboard", Default: defaults.ClipboardTimeout.String()},
+		func(s *Settings) **Duration { return &s.ClipboardTimeout }, time.Second),
+	intSetting(Key{Name: "generate_length", Help: "length of passwords from pm generate", Default: strconv.Itoa(defaults.GenerateLength)},
+		func(s *Settings) **int { return &s.GenerateLength }, 4, 128),
+	intSetting(Key{Name: "backup_keep", Help: "number of vault backups kept, 0 for no limit", Default: strconv.Itoa(defaults.BackupKeep)},
+		func(s *Settings) **int { return &s.BackupKeep }, 0, 10000),
+	durationSetting(Key{Name: "backup_max_age", Help: "age after which backups are pruned, 0 for no limit", Default: "0s"},
+		func(s *Settings) **Duration { return &s.BackupMaxAge }, 0),
+	durationSetting(Key{Name: "lock_timeout", Help: "how long to wait for another pm command", Default: defaults.LockTimeout.String()},
+		func(s *Settings) **Duration { return &s.LockTimeout }, 0),
+	intSetting(Key{Name: "history_keep", Help: "earlier versions kept of each entry, 0 for no limit", Default: strconv.Itoa(defaults.HistoryKeep)},
+		func(s *Settings) **int { return &s.HistoryKeep }, 0, 1000),
+	durationSetting(Key{Name: "trash_retention", Help: "how long deleted entries stay in the trash, 0 until emptied", Default: defaults.TrashRetention.String()},
+		func(s *Settings) **Duration { return &s.TrashRetention }, 0),
+}
+
+// Keys returns every configuration key, in the order pm config list shows
+// them
+func Keys() []Key {
+	keys := make([]Key, len(settings))
+	for i, s := range settings {
+		keys[i] = s.Key
+	}
+	return keys
+}
+
+// Get returns the value of key and whether it is set. Per-vault overrides
+// are addressed as vaults.<vault>.<key>.
+func (c *Config) Get(key string) (string, bool, error) {
+	s, vault, err := lookup(key)
+	if err != nil {
+		return "", false, err
+	}
+	if vault == "" {
+		value, ok := s.get(c, &c.Settings)
+		return value, ok, nil
+	}
+	overrides := c.Vaults[vault]
+	value, ok := s.get(c, &overrides)
+	return value, ok, nil
+}
+
+// Effective returns the value in effect for key: its own value, for a
+// per-vault override the value for all vaults, or else the default
+func (c *Config) Effective(key string) (string, error) {
+	s, vault, err := lookup(key)
+	if err != nil {
+		return "", err
+	}
+	if value, ok, _ := c.Get(key); ok {
+		return value, nil
+	}
+	if vault != "" {
+		if value, ok := s.get(c, &c.Settings); ok {
+			return value, nil
+		}
+	}
+	return s.Default, nil
+}
+
+// Set validates value and stores it under key
+func (c *Config) Set(key, value string) error {
+	s, vault, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	if vault == "" {
+		return s.set(c, &c.Settings, value)
+	}
+
+	overrides := c.Vaults[vault]
+	if err := s.set(c, &overrides, value); err != nil {
+		return err
+	}
+	if c.Vaults == nil {
+		c.Vaults = make(map[string]Settings)
+	}
+	c.Vaults[vault] = overrides
+	return nil
+}
+
+// Unset removes the value of key, so that its default applies again
+func (c *Config) Unset(key string) error {
+	s, vault, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	if vault == "" {
+		s.unset(c, &c.Settings)
+		return nil
+	}
+
+	overrides, ok := c.Vaults[vault]
+	if !ok {
+		return nil
+	}
+	s.unset(c, &overrides)
+	c.Vaults[vault] = overrides
+	return nil
+}
+
+// lookup finds the setting a key names, and the vault it overrides
+func lookup(key string) (setting, string, error) {
+	vault, name := splitKey(key)
+	for _, s := range settings {
+		if s.Name != name {
+			continue
+		}
+		if vault == "" {
+			return s, "", nil
+		}
+		if !s.PerVault {
+			return setting{}, "", fmt.Errorf("%s cannot be set per vault", name)
+		}
+		if err := storage.ValidateVaultName(vault); err != nil {
+			return setting{}, "", err
+		}
+		return s, vault, nil
+	}
+	return setting{}, "", unknownKeyError(key)
+}
+
+// unknownKeyError reports an unknown key, suggesting the closest known one
+func unknownKeyError(key string) error {
+	vault, name := splitKey(key)
+	best, bestDistance := "", 3
+	for _, s := range settings {
+		if d := editDistance(name, s.Name); d < bestDistance {
+			best, bestDistance = s.Name, d
+		}
+	}
+	if best == "" {
+		return fmt.Errorf("%w %q; run 'pm config list' to see the known keys", ErrUnknownKey, key)
+	}
+	if vault != "" {
+		best = vaultKey(vault, best)
+	}
+	return fmt.Errorf("%w %q; did you mean %q?", ErrUnknownKey, key, best)
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// stringSetting is a global string key
+func stringSetting(key Key, field func(*Config) *string, check func(string) error) setting {
+	return setting{
+		Key: key,
+		get: func(c *Config, _ *Settings) (string, bool) {
+			value := *field(c)
+			return value, value != ""
+		},
+		set: func(c *Config, _ *Settings, value string) error {
+			if err := check(value); err != nil {
+				return fmt.Errorf("invalid %s: %w", key.Name, err)
+			}
+			*field(c) = value
+			return nil
+		},
+		unset: func(c *Config, _ *Settings) {
+			*field(c) = ""
+		},
+	}
+}
+
+// durationSetting is a duration key that can be overridden per vault
+func durationSetting(key Key, field func(*Settings) **Duration, minimum time.Duration) setting {
+	key.PerVault = true
+	return setting{
+		Key: key,
+		get: func(_ *Config, s *Settings) (string, bool) {
+			if *field(s) == nil {
+				return "", false
+			}
+			return (*field(s)).String(), true
+		},
+		set: func(_ *Config, s *Settings, value string) error {
+			var d Duration
+			if err := d.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("invalid %s: %w", key.Name, err)
+			}
+			if d.Duration < minimum {
+				return fmt.Errorf("invalid %s: must be at least %s", key.Name, minimum)
+			}
+			*field(s) = &d
+			return nil
+		},
+		unset: func(_ *Config, s *Settings) {
+			*field(s) = nil
+		},
+	}
+}
+
+// intSetting is an integer key that can be overridden per vault
+func intSetting(key Key, field func(*Settings) **int, minimum, maximum int) setting {
+	key.PerVault = true
+	return setting{
+		Key: key,
+		get: func(_ *Config, s *Settings) (string, bool) {
+			if *field(s) == nil {
+				return "", false
+			}
+			return strconv.Itoa(**field(s)), true
+		},
+		set: func(_ *Config, s *Settings, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q is not a number", key.Name, value)
+			}
+			if n < minimum || n > maximum {
+				return fmt.Errorf("invalid %s: must be between %d and %d", key.Name, minimum, maximum)
+			}
+			*field(s) = &n
+			return nil
+		},
+		unset: func(_ *Config, s *Settings) {
+			*field(s) = nil
+		},
+	}
+}
+
+// checkDataDir requires an absolute data directory
+func checkDataDir(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("%q is not an absolute path", dir)
+	}
+	return nil
+}
+
+// checkBackend requires a backend storage.OpenBackend knows
+func checkBackend(kind string) error {
+	if kind != storage.BackendFile && kind != storage.BackendKV {
+		return fmt.Errorf("unknown backend %q, use %s or %s", kind, storage.BackendFile, storage.BackendKV)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSet_Get_Unset(t *testing.T) {
+	cfg := &Config{}
+
+	if err := cfg.Set("clipboard_timeout", "30s"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value, ok, err := cfg.Get("clipboard_timeout")
+	if err != nil || !ok || value != "30s" {
+		t.Errorf("Expected 30s, got %q (set %v, err %v)", value, ok, err)
+	}
+
+	if err := cfg.Unset("clipboard_timeout"); err != nil {
+		t.Fatalf("Unset failed: %v", err)
+	}
+	if _, ok, _ := cfg.Get("clipboard_timeout"); ok {
+		t.Error("clipboard_timeout should be unset")
+	}
+	if value, _ := cfg.Effective("clipboard_timeout"); value != "10s" {
+		t.Errorf("Expected the default 10s, got %q", value)
+	}
+}
+
+func TestSet_Invalid(t *testing.T) {
+	cfg := &Config{}
+	tests := map[string]string{
+		"clipboard_timeout": "soon",
+		"generate_length":   "2",
+		"backup_keep":       "-1",
+		"backend":           "s3",
+		"data_dir":          "relative/dir",
+		"default_vault":     "../other",
+	}
+	for key, value := range tests {
+		if err := cfg.Set(key, value); err == nil {
+			t.Errorf("Set(%s, %s) should fail", key, value)
+		}
+	}
+	if len(cfg.SetKeys()) != 0 {
+		t.Errorf("Invalid values should not be stored, got %v", cfg.SetKeys())
+	}
+}
+
+func TestSet_UnknownKey(t *testing.T) {
+	cfg := &Config{}
+
+	err := cfg.Set("clipboard_timout", "5s")
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Expected ErrUnknownKey, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `did you mean "clipboard_timeout"`) {
+		t.Errorf("Expected a suggestion, got %v", err)
+	}
+
+	err = cfg.Set("vaults.work.generate_lenght", "20")
+	if !strings.Contains(err.Error(), `did you mean "vaults.work.generate_length"`) {
+		t.Errorf("Expected a per-vault suggestion, got %v", err)
+	}
+
+	err = cfg.Set("colour", "blue")
+	if !errors.Is(err, ErrUnknownKey) || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("Expected no suggestion for an unrelated key, got %v", err)
+	}
+}
+
+func TestSet_GlobalKeyPerVault(t *testing.T) {
+	cfg := &Config{}
+	if err := cfg.Set("vaults.work.backend", "kv"); err == nil {
+		t.Error("backend should not be settable per vault")
+	}
+}
+
+func TestOptions_PerVault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Set("generate_length", "24")
+	cfg.Set("backup_keep", "5")
+	cfg.Set("vaults.work.generate_length", "32")
+	cfg.Set("vaults.work.lock_timeout", "1m")
+
+	work := cfg.Options("work")
+	if work.GenerateLength != 32 || work.BackupKeep != 5 || work.LockTimeout != time.Minute {
+		t.Errorf("Unexpected options for work: %+v", work)
+	}
+
+	defaults := DefaultOptions()
+	other := cfg.Options("default")
+	if other.GenerateLength != 24 || other.LockTimeout != defaults.LockTimeout {
+		t.Errorf("Unexpected options for default: %+v", other)
+	}
+
+	if value, _ := cfg.Effective("vaults.other.generate_length"); value != "24" {
+		t.Errorf("Expected the global value 24, got %q", value)
+	}
+}
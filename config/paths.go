@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// EnvVaultDir is the environment variable overriding the data directory
+const EnvVaultDir = "PM_VAULT_DIR"
+
+// AppDirName is the name of the data and config directories below the XDG
+// base directories
+const AppDirName = "passwordmanager"
+
+// LegacyDirName is the data directory in the home directory used before
+// XDG base directories were supported
+const LegacyDirName = ".passwordmanager"
+
+// Paths are the directories pm works in
+type Paths struct {
+	// DataDir holds the vault, user.dat and backups
+	DataDir string
+
+	// ConfigDir holds the configuration file
+	ConfigDir string
+
+	// LegacyDir, when set, is the old data directory that should be moved
+	// to DataDir if DataDir does not exist yet
+	LegacyDir string
+}
+
+// ResolvePaths works out the data and config directories. An explicit
+// vaultDir, as given with --vault-dir, wins over $PM_VAULT_DIR; either keeps
+// the configuration inside the data directory so the vault is
+// self-contained. Otherwise the XDG base directories are used:
+// $XDG_DATA_HOME/passwordmanager and $XDG_CONFIG_HOME/passwordmanager,
+// defaulting to ~/.local/share and ~/.config.
+func ResolvePaths(vaultDir string) (Paths, error) {
+	if vaultDir == "" {
+		vaultDir = os.Getenv(EnvVaultDir)
+	}
+	if vaultDir != "" {
+		dir, err := filepath.Abs(vaultDir)
+		if err != nil {
+			return Paths{}, fmt.Errorf("invalid vault directory: %w", err)
+		}
+		return Paths{DataDir: dir, ConfigDir: dir}, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Paths{}, fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return Paths{
+		DataDir:   filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(home, ".local", "share")), AppDirName),
+		ConfigDir: filepath.Join(xdgDir("XDG_CONFIG_HOME", filepath.Join(home, ".config")), AppDirName),
+		LegacyDir: filepath.Join(home, LegacyDirName),
+	}, nil
+}
+
+// xdgDir returns the XDG base directory named by env, or fallback when it
+// is unset. The specification requires absolute paths and says to ignore
+// relative ones.
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	return fallback
+}
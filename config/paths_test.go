@@ -0,0 +1,51 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePaths_XDG(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(EnvVaultDir, "")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+	t.Setenv("XDG_CONFIG_HOME", "relative/config")
+
+	paths, err := ResolvePaths("")
+	if err != nil {
+		t.Fatalf("ResolvePaths failed: %v", err)
+	}
+	if paths.DataDir != filepath.Join("/xdg/data", AppDirName) {
+		t.Errorf("Unexpected data directory %s", paths.DataDir)
+	}
+	// Relative XDG paths are ignored
+	if paths.ConfigDir != filepath.Join(home, ".config", AppDirName) {
+		t.Errorf("Unexpected config directory %s", paths.ConfigDir)
+	}
+	if paths.LegacyDir != filepath.Join(home, LegacyDirName) {
+		t.Errorf("Unexpected legacy directory %s", paths.LegacyDir)
+	}
+}
+
+func TestResolvePaths_Override(t *testing.T) {
+	t.Setenv(EnvVaultDir, "/env/vault")
+
+	paths, err := ResolvePaths("")
+	if err != nil {
+		t.Fatalf("ResolvePaths failed: %v", err)
+	}
+	want := Paths{DataDir: "/env/vault", ConfigDir: "/env/vault"}
+	if paths != want {
+		t.Errorf("Expected %+v, got %+v", want, paths)
+	}
+
+	// The flag wins over the environment
+	paths, err = ResolvePaths("/flag/vault")
+	if err != nil {
+		t.Fatalf("ResolvePaths failed: %v", err)
+	}
+	if paths.DataDir != "/flag/vault" || paths.LegacyDir != "" {
+		t.Errorf("Unexpected paths %+v", paths)
+	}
+}
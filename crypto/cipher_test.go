@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCipherSuite_SealOpen(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	plaintext := []byte("vault contents")
+	aad := []byte("header")
+
+	for _, suite := range []CipherSuite{CipherAES256GCM, CipherXChaCha20Poly1305} {
+		t.Run(suite.String(), func(t *testing.T) {
+			sealed, err := suite.Seal(key, plaintext, aad)
+			if err != nil {
+				t.Fatalf("Seal returned error: %v", err)
+			}
+
+			opened, err := suite.Open(key, sealed, aad)
+			if err != nil {
+				t.Fatalf("Open returned error: %v", err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("Open = %q, want %q", opened, plaintext)
+			}
+
+			if _, err := suite.Open(key, sealed, []byte("other header")); !errors.Is(err, ErrAuthFailed) {
+				t.Errorf("Open with different additional data should fail with ErrAuthFailed, got %v", err)
+			}
+
+			sealed2, _ := suite.Seal(key, plaintext, aad)
+			if bytes.Equal(sealed, sealed2) {
+				t.Error("Seal should use a random nonce")
+			}
+		})
+	}
+}
+
+func TestCipherSuite_NotInterchangeable(t *testing.T) {
+	key := make([]byte, KeySize)
+
+	sealed, err := CipherXChaCha20Poly1305.Seal(key, []byte("data"), nil)
+	if err != nil {
+		t.Fatalf("Seal returned error: %v", err)
+	}
+	if _, err := CipherAES256GCM.Open(key, sealed, nil); err == nil {
+		t.Error("AES-256-GCM should not open XChaCha20-Poly1305 ciphertext")
+	}
+}
+
+func TestCipherSuite_NonceSizes(t *testing.T) {
+	key := make([]byte, KeySize)
+
+	aes, _ := CipherAES256GCM.NewAEAD(key)
+	if aes.NonceSize() != 12 {
+		t.Errorf("Expected 12-byte AES-GCM nonce, got %d", aes.NonceSize())
+	}
+	xchacha, _ := CipherXChaCha20Poly1305.NewAEAD(key)
+	if xchacha.NonceSize() != 24 {
+		t.Errorf("Expected 24-byte XChaCha20 nonce, got %d", xchacha.NonceSize())
+	}
+}
+
+func TestParseCipherSuite(t *testing.T) {
+	for _, suite := range []CipherSuite{CipherAES256GCM, CipherXChaCha20Poly1305} {
+		parsed, err := ParseCipherSuite(suite.String())
+		if err != nil || parsed != suite {
+			t.Errorf("ParseCipherSuite(%q) = %v, %v", suite.String(), parsed, err)
+		}
+	}
+	if _, err := ParseCipherSuite("des"); err == nil {
+		t.Error("ParseCipherSuite should reject unknown ciphers")
+	}
+	if CipherSuite(99).Valid() {
+		t.Error("Unknown cipher suite should not be valid")
+	}
+}
@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"encoding/binary"
+)
+
+// aadContextMagic prefixes encoded contexts so they can never collide with
+// other additional data
+var aadContextMagic = []byte("pm-aad\x00")
+
+// AADContext identifies what a ciphertext is and where it belongs. It is
+// passed as additional authenticated data, so a ciphertext only opens in the
+// context it was sealed in and cannot be substituted for a different file,
+// role or format version.
+type AADContext struct {
+	VaultID       []byte
+	Role          string
+	FormatVersion uint16
+}
+
+// IsZero reports whether no context was given
+func (c AADContext) IsZero() bool {
+	return len(c.VaultID) == 0 && c.Role == "" && c.FormatVersion == 0
+}
+
+// Bytes returns the canonical encoding of the context, or nil for the zero
+// context so that data sealed without a context keeps opening
+func (c AADContext) Bytes() []byte {
+	if c.IsZero() {
+		return nil
+	}
+
+	buf := make([]byte, 0, len(aadContextMagic)+2+1+len(c.VaultID)+1+len(c.Role))
+	buf = append(buf, aadContextMagic...)
+	buf = binary.BigEndian.AppendUint16(buf, c.FormatVersion)
+	buf = append(buf, byte(len(c.VaultID)))
+	buf = append(buf, c.VaultID...)
+	buf = append(buf, byte(len(c.Role)))
+	return append(buf, c.Role...)
+}
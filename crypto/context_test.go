@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecryptData_ContextMismatch(t *testing.T) {
+	params := fastKDFParams(t, KDFArgon2id)
+	ctx := AADContext{VaultID: []byte("0123456789abcdef"), Role: "vault", FormatVersion: 4}
+
+	encrypted, err := EncryptDataWithParams([]byte("secret"), SecretFromString("password"), params, ctx)
+	if err != nil {
+		t.Fatalf("EncryptDataWithParams returned error: %v", err)
+	}
+
+	decrypted, err := DecryptData(encrypted, SecretFromString("password"), ctx)
+	if err != nil {
+		t.Fatalf("DecryptData returned error: %v", err)
+	}
+	if string(decrypted) != "secret" {
+		t.Errorf("Decrypted data mismatch: got %q", decrypted)
+	}
+
+	others := []AADContext{
+		{},
+		{VaultID: []byte("fedcba9876543210"), Role: "vault", FormatVersion: 4},
+		{VaultID: ctx.VaultID, Role: "backup", FormatVersion: 4},
+		{VaultID: ctx.VaultID, Role: "vault", FormatVersion: 3},
+	}
+	for _, other := range others {
+		if _, err := DecryptData(encrypted, SecretFromString("password"), other); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("DecryptData with context %+v: expected ErrAuthFailed, got %v", other, err)
+		}
+	}
+}
+
+func TestAADContext_Bytes(t *testing.T) {
+	if (AADContext{}).Bytes() != nil {
+		t.Error("Zero context should encode to nil")
+	}
+
+	// Field boundaries are length-prefixed, so shifting bytes between the
+	// vault ID and role must change the encoding
+	a := AADContext{VaultID: []byte("ab"), Role: "c"}.Bytes()
+	b := AADContext{VaultID: []byte("a"), Role: "bc"}.Bytes()
+	if string(a) == string(b) {
+		t.Error("Different contexts encoded identically")
+	}
+}
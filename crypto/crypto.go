@@ -1,74 +1,119 @@
 package crypto
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 )
 
-// EncryptData encrypts data using AES-256-GCM
-func EncryptData(data []byte, password string) ([]byte, error) {
-	// Create a new cipher block
-	key := sha256.Sum256([]byte(password))
-	block, err := aes.NewCipher(key[:])
+// ErrAuthFailed is returned when a ciphertext fails authentication, either
+// because the key is wrong or because the data has been modified
+var ErrAuthFailed = errors.New("message authentication failed")
+
+// dataMagic prefixes ciphertexts produced by EncryptData so they can be told
+// apart from the legacy unsalted SHA-256 format
+var dataMagic = []byte("PMK1")
+
+// EncryptData encrypts data using AES-256-GCM under an Argon2id-derived key.
+// The KDF parameters are stored in front of the ciphertext, and ctx is bound
+// to it as additional data.
+func EncryptData(data []byte, password *Secret, ctx AADContext) ([]byte, error) {
+	params, err := DefaultKDFParams(KDFArgon2id)
 	if err != nil {
 		return nil, err
 	}
+	return EncryptDataWithParams(data, password, params, ctx)
+}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+// EncryptDataWithParams encrypts data using AES-256-GCM under a key derived
+// with the given KDF parameters, binding ctx as additional data
+func EncryptDataWithParams(data []byte, password *Secret, params KDFParams, ctx AADContext) ([]byte, error) {
+	header, err := params.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
 
-	// Create nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+	key, err := DeriveKey(password, params)
+	if err != nil {
 		return nil, err
 	}
+	defer Wipe(key)
 
-	// Encrypt data
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
-}
-
-// DecryptData decrypts data using AES-256-GCM
-func DecryptData(encryptedData []byte, password string) ([]byte, error) {
-	// Create a new cipher block
-	key := sha256.Sum256([]byte(password))
-	block, err := aes.NewCipher(key[:])
+	ciphertext, err := Seal(key, data, ctx.Bytes())
 	if err != nil {
 		return nil, err
 	}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+	out := make([]byte, 0, len(dataMagic)+len(header)+len(ciphertext))
+	out = append(out, dataMagic...)
+	out = append(out, header...)
+	return append(out, ciphertext...), nil
+}
+
+// DecryptData decrypts data produced by EncryptData or EncryptDataWithParams.
+// ctx must match the context the data was encrypted with.
+func DecryptData(encryptedData []byte, password *Secret, ctx AADContext) ([]byte, error) {
+	params, ciphertext, err := SplitKDFParams(encryptedData)
 	if err != nil {
 		return nil, err
 	}
 
-	// Extract nonce
-	nonceSize := gcm.NonceSize()
-	if len(encryptedData) < nonceSize {
-		return nil, fmt.Errorf("ciphertext too short")
+	key, err := DeriveKey(password, params)
+	if err != nil {
+		return nil, err
 	}
+	defer Wipe(key)
 
-	nonce, ciphertext := encryptedData[:nonceSize], encryptedData[nonceSize:]
+	return Open(key, ciphertext, ctx.Bytes())
+}
 
-	// Decrypt data
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+// SplitKDFParams parses the KDF parameters from the front of an EncryptData
+// ciphertext and returns them with the remaining nonce and ciphertext
+func SplitKDFParams(encryptedData []byte) (KDFParams, []byte, error) {
+	if IsLegacyCiphertext(encryptedData) {
+		return KDFParams{}, nil, fmt.Errorf("unrecognized ciphertext format")
+	}
+	params, n, err := UnmarshalKDFParams(encryptedData[len(dataMagic):])
 	if err != nil {
-		return nil, err
+		return KDFParams{}, nil, err
 	}
+	return params, encryptedData[len(dataMagic)+n:], nil
+}
+
+// IsLegacyCiphertext reports whether data lacks the KDF header and was
+// therefore produced by the original unsalted SHA-256 scheme
+func IsLegacyCiphertext(data []byte) bool {
+	return !bytes.HasPrefix(data, dataMagic)
+}
+
+// DecryptLegacyData decrypts data written before KDF parameters were
+// introduced, where the key was a single SHA-256 of the password
+func DecryptLegacyData(encryptedData []byte, password *Secret) ([]byte, error) {
+	key := sha256.Sum256(password.Bytes())
+	defer Wipe(key[:])
+	return Open(key[:], encryptedData, nil)
+}
+
+// Seal encrypts plaintext with AES-256-GCM under key and returns nonce || ciphertext.
+// additionalData is authenticated but not encrypted and must be passed to Open unchanged.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	return CipherAES256GCM.Seal(key, plaintext, additionalData)
+}
 
-	return plaintext, nil
+// Open decrypts nonce || ciphertext produced by Seal
+func Open(key, encryptedData, additionalData []byte) ([]byte, error) {
+	return CipherAES256GCM.Open(key, encryptedData, additionalData)
 }
 
-// HashPassword creates a SHA-256 hash of the password with salt
+// HashPassword creates a SHA-256 hash of the password with salt.
+//
+// Deprecated: a single SHA-256 is far too fast to use as a password
+// verifier. It is only kept to check user.dat files written by older
+// versions while they are migrated.
 func HashPassword(password, salt string) string {
 	hash := sha256.Sum256([]byte(password + salt))
 	return base64.StdEncoding.EncodeToString(hash[:])
@@ -154,7 +154,7 @@ func TestEncryptData(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			encrypted, err := EncryptData(tt.data, tt.password)
+			encrypted, err := EncryptData(tt.data, SecretFromString(tt.password), AADContext{})
 			if err != nil {
 				t.Fatalf("EncryptData returned error: %v", err)
 			}
@@ -175,7 +175,7 @@ func TestEncryptData(t *testing.T) {
 			}
 
 			// Check that multiple encryptions produce different ciphertexts (due to random nonce)
-			encrypted2, err := EncryptData(tt.data, tt.password)
+			encrypted2, err := EncryptData(tt.data, SecretFromString(tt.password), AADContext{})
 			if err != nil {
 				t.Fatalf("EncryptData returned error on second encryption: %v", err)
 			}
@@ -218,13 +218,13 @@ func TestDecryptData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Encrypt first
-			encrypted, err := EncryptData(tt.data, tt.password)
+			encrypted, err := EncryptData(tt.data, SecretFromString(tt.password), AADContext{})
 			if err != nil {
 				t.Fatalf("EncryptData returned error: %v", err)
 			}
 
 			// Decrypt with correct password
-			decrypted, err := DecryptData(encrypted, tt.password)
+			decrypted, err := DecryptData(encrypted, SecretFromString(tt.password), AADContext{})
 			if err != nil {
 				t.Fatalf("DecryptData returned error: %v", err)
 			}
@@ -236,7 +236,7 @@ func TestDecryptData(t *testing.T) {
 
 			// Check that decryption with wrong password fails
 			wrongPassword := tt.password + "wrong"
-			_, err = DecryptData(encrypted, wrongPassword)
+			_, err = DecryptData(encrypted, SecretFromString(wrongPassword), AADContext{})
 			if err == nil {
 				t.Error("DecryptData with wrong password should return error")
 			}
@@ -269,12 +269,12 @@ func TestEncryptDecryptRoundTrip(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			encrypted, err := EncryptData(tc.data, tc.password)
+			encrypted, err := EncryptData(tc.data, SecretFromString(tc.password), AADContext{})
 			if err != nil {
 				t.Fatalf("EncryptData failed: %v", err)
 			}
 
-			decrypted, err := DecryptData(encrypted, tc.password)
+			decrypted, err := DecryptData(encrypted, SecretFromString(tc.password), AADContext{})
 			if err != nil {
 				t.Fatalf("DecryptData failed: %v", err)
 			}
@@ -315,7 +315,7 @@ func TestDecryptData_InvalidCiphertext(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := DecryptData(tt.ciphertext, tt.password)
+			_, err := DecryptData(tt.ciphertext, SecretFromString(tt.password), AADContext{})
 			if tt.expectError && err == nil {
 				t.Error("Expected error but got nil")
 			}
@@ -0,0 +1,208 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeySize is the size in bytes of every derived encryption key
+const KeySize = 32
+
+// KDFSaltSize is the size in bytes of freshly generated KDF salts
+const KDFSaltSize = 16
+
+// KDFAlgorithm identifies a password-based key derivation function
+type KDFAlgorithm uint8
+
+const (
+	KDFArgon2id KDFAlgorithm = iota + 1
+	KDFScrypt
+	KDFPBKDF2
+)
+
+// Upper bounds on the KDF costs. The parameters are read from the vault
+// header before anything is authenticated, so without them a damaged or
+// tampered header could make unlock allocate any amount of memory or run
+// for hours before the password check fails.
+const (
+	maxKDFMemory      = 4 * 1024 * 1024 // KiB, 4 GiB for argon2id and scrypt
+	maxArgon2Time     = 64
+	maxKDFParallelism = 64
+	maxPBKDF2Rounds   = 50000000
+)
+
+// scryptBlockSize is the scrypt r parameter. With r=8 one unit of N costs
+// exactly 1 KiB, so KDFParams.Memory maps directly onto N.
+const scryptBlockSize = 8
+
+// String returns the name used for the algorithm on the command line
+func (a KDFAlgorithm) String() string {
+	switch a {
+	case KDFArgon2id:
+		return "argon2id"
+	case KDFScrypt:
+		return "scrypt"
+	case KDFPBKDF2:
+		return "pbkdf2"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(a))
+	}
+}
+
+// ParseKDFAlgorithm converts a command-line name into a KDFAlgorithm
+func ParseKDFAlgorithm(name string) (KDFAlgorithm, error) {
+	switch strings.ToLower(name) {
+	case "argon2id", "argon2":
+		return KDFArgon2id, nil
+	case "scrypt":
+		return KDFScrypt, nil
+	case "pbkdf2", "pbkdf2-sha256":
+		return KDFPBKDF2, nil
+	default:
+		return 0, fmt.Errorf("unknown KDF %q (expected argon2id, scrypt or pbkdf2)", name)
+	}
+}
+
+// KDFParams holds everything needed to re-derive a key from a password.
+//
+// The meaning of the cost fields depends on the algorithm:
+//   - argon2id: Iterations is the time cost, Memory is in KiB, Parallelism is the lane count
+//   - scrypt:   Memory is N (a power of two, 1 KiB each), Parallelism is p, Iterations is unused
+//   - pbkdf2:   Iterations is the HMAC-SHA256 round count, Memory and Parallelism are unused
+type KDFParams struct {
+	Algorithm   KDFAlgorithm
+	Salt        []byte
+	Iterations  uint32
+	Memory      uint32
+	Parallelism uint8
+}
+
+// DefaultKDFParams returns recommended parameters for the algorithm with a fresh random salt
+func DefaultKDFParams(alg KDFAlgorithm) (KDFParams, error) {
+	params := KDFParams{Algorithm: alg}
+	switch alg {
+	case KDFArgon2id:
+		params.Iterations = 3
+		params.Memory = 64 * 1024
+		params.Parallelism = 4
+	case KDFScrypt:
+		params.Iterations = 1
+		params.Memory = 1 << 15
+		params.Parallelism = 1
+	case KDFPBKDF2:
+		params.Iterations = 600000
+	default:
+		return KDFParams{}, fmt.Errorf("unsupported KDF algorithm: %s", alg)
+	}
+
+	salt, err := NewKDFSalt()
+	if err != nil {
+		return KDFParams{}, err
+	}
+	params.Salt = salt
+	return params, nil
+}
+
+// NewKDFSalt generates a random KDFSaltSize-byte salt
+func NewKDFSalt() ([]byte, error) {
+	salt := make([]byte, KDFSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// Validate checks that the parameters are usable, not dangerously weak,
+// and not so costly that deriving a key would exhaust the machine
+func (p KDFParams) Validate() error {
+	if len(p.Salt) < 8 {
+		return fmt.Errorf("KDF salt too short: %d bytes", len(p.Salt))
+	}
+	switch p.Algorithm {
+	case KDFArgon2id:
+		if p.Iterations < 1 || p.Iterations > maxArgon2Time {
+			return fmt.Errorf("argon2id iterations must be between 1 and %d", maxArgon2Time)
+		}
+		if p.Memory < 8*1024 || p.Memory > maxKDFMemory {
+			return fmt.Errorf("argon2id memory must be between 8192 and %d KiB", maxKDFMemory)
+		}
+		if p.Parallelism < 1 || p.Parallelism > maxKDFParallelism {
+			return fmt.Errorf("argon2id parallelism must be between 1 and %d", maxKDFParallelism)
+		}
+	case KDFScrypt:
+		if p.Memory < 1<<14 || p.Memory > 1<<22 || p.Memory&(p.Memory-1) != 0 {
+			return fmt.Errorf("scrypt N must be a power of two between 16384 and %d", 1<<22)
+		}
+		if p.Parallelism < 1 || p.Parallelism > maxKDFParallelism {
+			return fmt.Errorf("scrypt parallelism must be between 1 and %d", maxKDFParallelism)
+		}
+	case KDFPBKDF2:
+		if p.Iterations < 100000 || p.Iterations > maxPBKDF2Rounds {
+			return fmt.Errorf("pbkdf2 iterations must be between 100000 and %d", maxPBKDF2Rounds)
+		}
+	default:
+		return fmt.Errorf("unsupported KDF algorithm: %s", p.Algorithm)
+	}
+	return nil
+}
+
+// DeriveKey derives a KeySize-byte key from the password using the given
+// parameters. The caller should Wipe the key once it is done with it.
+func DeriveKey(password *Secret, params KDFParams) ([]byte, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch params.Algorithm {
+	case KDFArgon2id:
+		return argon2.IDKey(password.Bytes(), params.Salt, params.Iterations, params.Memory, params.Parallelism, KeySize), nil
+	case KDFScrypt:
+		return scrypt.Key(password.Bytes(), params.Salt, int(params.Memory), scryptBlockSize, int(params.Parallelism), KeySize)
+	case KDFPBKDF2:
+		return pbkdf2.Key(password.Bytes(), params.Salt, int(params.Iterations), KeySize, sha256.New), nil
+	}
+	return nil, fmt.Errorf("unsupported KDF algorithm: %s", params.Algorithm)
+}
+
+// MarshalBinary encodes the parameters as
+// algorithm(1) | iterations(4) | memory(4) | parallelism(1) | saltLen(1) | salt
+func (p KDFParams) MarshalBinary() ([]byte, error) {
+	if len(p.Salt) > 255 {
+		return nil, fmt.Errorf("KDF salt too long: %d bytes", len(p.Salt))
+	}
+	buf := make([]byte, 0, 11+len(p.Salt))
+	buf = append(buf, byte(p.Algorithm))
+	buf = binary.BigEndian.AppendUint32(buf, p.Iterations)
+	buf = binary.BigEndian.AppendUint32(buf, p.Memory)
+	buf = append(buf, p.Parallelism, byte(len(p.Salt)))
+	buf = append(buf, p.Salt...)
+	return buf, nil
+}
+
+// UnmarshalKDFParams decodes parameters written by MarshalBinary and
+// returns the number of bytes consumed
+func UnmarshalKDFParams(data []byte) (KDFParams, int, error) {
+	if len(data) < 11 {
+		return KDFParams{}, 0, fmt.Errorf("KDF parameters truncated")
+	}
+	p := KDFParams{
+		Algorithm:   KDFAlgorithm(data[0]),
+		Iterations:  binary.BigEndian.Uint32(data[1:5]),
+		Memory:      binary.BigEndian.Uint32(data[5:9]),
+		Parallelism: data[9],
+	}
+	saltLen := int(data[10])
+	if len(data) < 11+saltLen {
+		return KDFParams{}, 0, fmt.Errorf("KDF salt truncated")
+	}
+	p.Salt = append([]byte(nil), data[11:11+saltLen]...)
+	return p, 11 + saltLen, nil
+}
@@ -0,0 +1,191 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+// fastKDFParams returns the cheapest parameters Validate accepts for each algorithm
+func fastKDFParams(t *testing.T, alg KDFAlgorithm) KDFParams {
+	params, err := DefaultKDFParams(alg)
+	if err != nil {
+		t.Fatalf("DefaultKDFParams returned error: %v", err)
+	}
+	switch alg {
+	case KDFArgon2id:
+		params.Iterations, params.Memory, params.Parallelism = 1, 8*1024, 1
+	case KDFScrypt:
+		params.Memory, params.Parallelism = 1<<14, 1
+	case KDFPBKDF2:
+		params.Iterations = 100000
+	}
+	return params
+}
+
+func TestDeriveKey(t *testing.T) {
+	for _, alg := range []KDFAlgorithm{KDFArgon2id, KDFScrypt, KDFPBKDF2} {
+		t.Run(alg.String(), func(t *testing.T) {
+			params := fastKDFParams(t, alg)
+
+			key, err := DeriveKey(SecretFromString("password"), params)
+			if err != nil {
+				t.Fatalf("DeriveKey returned error: %v", err)
+			}
+			if len(key) != KeySize {
+				t.Errorf("Expected key length %d, got %d", KeySize, len(key))
+			}
+
+			key2, _ := DeriveKey(SecretFromString("password"), params)
+			if !bytes.Equal(key, key2) {
+				t.Error("DeriveKey is not deterministic")
+			}
+
+			key3, _ := DeriveKey(SecretFromString("password2"), params)
+			if bytes.Equal(key, key3) {
+				t.Error("Different passwords produced same key")
+			}
+
+			other := params
+			other.Salt = append([]byte{0}, params.Salt[1:]...)
+			key4, _ := DeriveKey(SecretFromString("password"), other)
+			if bytes.Equal(key, key4) {
+				t.Error("Different salts produced same key")
+			}
+		})
+	}
+}
+
+func TestKDFParams_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *KDFParams)
+	}{
+		{"short salt", func(p *KDFParams) { p.Salt = []byte{1, 2, 3} }},
+		{"argon2id low memory", func(p *KDFParams) { p.Memory = 1024 }},
+		{"argon2id zero iterations", func(p *KDFParams) { p.Iterations = 0 }},
+		{"argon2id zero parallelism", func(p *KDFParams) { p.Parallelism = 0 }},
+		{"argon2id huge memory", func(p *KDFParams) { p.Memory = 1 << 30 }},
+		{"argon2id huge iterations", func(p *KDFParams) { p.Iterations = 1 << 31 }},
+		{"argon2id huge parallelism", func(p *KDFParams) { p.Parallelism = 255 }},
+		{"unknown algorithm", func(p *KDFParams) { p.Algorithm = 99 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := fastKDFParams(t, KDFArgon2id)
+			tt.modify(&params)
+			if err := params.Validate(); err == nil {
+				t.Error("Validate should have rejected parameters")
+			}
+		})
+	}
+
+	scryptParams := fastKDFParams(t, KDFScrypt)
+	scryptParams.Memory = 20000
+	if err := scryptParams.Validate(); err == nil {
+		t.Error("Validate should reject non power of two scrypt N")
+	}
+	scryptParams.Memory = 1 << 23
+	if err := scryptParams.Validate(); err == nil {
+		t.Error("Validate should reject scrypt N above 2^22")
+	}
+	scryptParams.Memory = 1 << 22
+	scryptParams.Parallelism = 255
+	if err := scryptParams.Validate(); err == nil {
+		t.Error("Validate should reject huge scrypt parallelism")
+	}
+
+	pbkdf2Params := fastKDFParams(t, KDFPBKDF2)
+	pbkdf2Params.Iterations = 1 << 31
+	if err := pbkdf2Params.Validate(); err == nil {
+		t.Error("Validate should reject huge pbkdf2 iteration counts")
+	}
+
+	// The largest accepted costs still validate
+	argonParams := fastKDFParams(t, KDFArgon2id)
+	argonParams.Memory, argonParams.Iterations, argonParams.Parallelism = maxKDFMemory, maxArgon2Time, maxKDFParallelism
+	if err := argonParams.Validate(); err != nil {
+		t.Errorf("Validate rejected the maximum argon2id costs: %v", err)
+	}
+}
+
+func TestKDFParams_MarshalRoundTrip(t *testing.T) {
+	params := fastKDFParams(t, KDFScrypt)
+
+	encoded, err := params.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary returned error: %v", err)
+	}
+
+	decoded, n, err := UnmarshalKDFParams(append(encoded, 0xff))
+	if err != nil {
+		t.Fatalf("UnmarshalKDFParams returned error: %v", err)
+	}
+	if n != len(encoded) {
+		t.Errorf("Expected %d bytes consumed, got %d", len(encoded), n)
+	}
+	if decoded.Algorithm != params.Algorithm || decoded.Iterations != params.Iterations ||
+		decoded.Memory != params.Memory || decoded.Parallelism != params.Parallelism ||
+		!bytes.Equal(decoded.Salt, params.Salt) {
+		t.Errorf("Round trip mismatch: got %+v, want %+v", decoded, params)
+	}
+
+	if _, _, err := UnmarshalKDFParams(encoded[:len(encoded)-1]); err == nil {
+		t.Error("UnmarshalKDFParams should reject truncated input")
+	}
+}
+
+func TestEncryptDataWithParams_RecordsParams(t *testing.T) {
+	params := fastKDFParams(t, KDFPBKDF2)
+
+	encrypted, err := EncryptDataWithParams([]byte("secret"), SecretFromString("password"), params, AADContext{})
+	if err != nil {
+		t.Fatalf("EncryptDataWithParams returned error: %v", err)
+	}
+
+	stored, _, err := SplitKDFParams(encrypted)
+	if err != nil {
+		t.Fatalf("SplitKDFParams returned error: %v", err)
+	}
+	if stored.Algorithm != KDFPBKDF2 || stored.Iterations != params.Iterations {
+		t.Errorf("Stored params mismatch: got %+v", stored)
+	}
+
+	decrypted, err := DecryptData(encrypted, SecretFromString("password"), AADContext{})
+	if err != nil {
+		t.Fatalf("DecryptData returned error: %v", err)
+	}
+	if string(decrypted) != "secret" {
+		t.Errorf("Decrypted data mismatch: got %s", decrypted)
+	}
+}
+
+func TestDecryptLegacyData(t *testing.T) {
+	// Build a ciphertext the way EncryptData did before KDF support
+	key := sha256.Sum256([]byte("password"))
+	block, _ := aes.NewCipher(key[:])
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	legacy := gcm.Seal(nonce, nonce, []byte("old data"), nil)
+
+	if !IsLegacyCiphertext(legacy) {
+		t.Fatal("IsLegacyCiphertext should report legacy data")
+	}
+
+	decrypted, err := DecryptLegacyData(legacy, SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("DecryptLegacyData returned error: %v", err)
+	}
+	if string(decrypted) != "old data" {
+		t.Errorf("Decrypted data mismatch: got %s", decrypted)
+	}
+
+	if _, err := DecryptData(legacy, SecretFromString("password"), AADContext{}); err == nil {
+		t.Error("DecryptData should not accept legacy ciphertext")
+	}
+}
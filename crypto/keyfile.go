@@ -0,0 +1,42 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// KeyFileSize is the size in bytes of key files created by GenerateKeyFile
+const KeyFileSize = 64
+
+// GenerateKeyFile returns KeyFileSize random bytes suitable for use as a key file
+func GenerateKeyFile() ([]byte, error) {
+	contents := make([]byte, KeyFileSize)
+	if _, err := io.ReadFull(rand.Reader, contents); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// DeriveKeyWithKeyFile derives a key from the password as DeriveKey does and
+// mixes a hash of the key file contents into the result, so both are needed
+// to reproduce it
+func DeriveKeyWithKeyFile(password *Secret, keyFile []byte, params KDFParams) ([]byte, error) {
+	if len(keyFile) == 0 {
+		return nil, fmt.Errorf("key file is empty")
+	}
+
+	key, err := DeriveKey(password, params)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(key)
+
+	fileHash := sha256.Sum256(keyFile)
+	defer Wipe(fileHash[:])
+	mac := hmac.New(sha256.New, key)
+	mac.Write(fileHash[:])
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateKeyFile(t *testing.T) {
+	first, err := GenerateKeyFile()
+	if err != nil {
+		t.Fatalf("GenerateKeyFile returned error: %v", err)
+	}
+	if len(first) != KeyFileSize {
+		t.Errorf("Expected %d bytes, got %d", KeyFileSize, len(first))
+	}
+
+	second, _ := GenerateKeyFile()
+	if bytes.Equal(first, second) {
+		t.Error("GenerateKeyFile produced the same contents twice")
+	}
+}
+
+func TestDeriveKeyWithKeyFile(t *testing.T) {
+	params := fastKDFParams(t, KDFArgon2id)
+	keyFile := []byte("key file contents")
+
+	key, err := DeriveKeyWithKeyFile(SecretFromString("password"), keyFile, params)
+	if err != nil {
+		t.Fatalf("DeriveKeyWithKeyFile returned error: %v", err)
+	}
+	if len(key) != KeySize {
+		t.Errorf("Expected key length %d, got %d", KeySize, len(key))
+	}
+
+	again, _ := DeriveKeyWithKeyFile(SecretFromString("password"), keyFile, params)
+	if !bytes.Equal(key, again) {
+		t.Error("DeriveKeyWithKeyFile is not deterministic")
+	}
+
+	passwordOnly, _ := DeriveKey(SecretFromString("password"), params)
+	if bytes.Equal(key, passwordOnly) {
+		t.Error("Key file did not change the derived key")
+	}
+
+	otherFile, _ := DeriveKeyWithKeyFile(SecretFromString("password"), []byte("other contents"), params)
+	if bytes.Equal(key, otherFile) {
+		t.Error("Different key files produced the same key")
+	}
+
+	if _, err := DeriveKeyWithKeyFile(SecretFromString("password"), nil, params); err == nil {
+		t.Error("DeriveKeyWithKeyFile should reject an empty key file")
+	}
+}
@@ -0,0 +1,76 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// recoveryCodeBytes is the entropy of a recovery code (160 bits)
+const recoveryCodeBytes = 20
+
+// recoveryCodeGroup is the number of characters between dashes when a code is displayed
+const recoveryCodeGroup = 4
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCode returns a random recovery code formatted for printing,
+// e.g. ABCD-EFGH-IJKL-MNOP-QRST-UVWX-YZ23-4567
+func GenerateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeBytes)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", err
+	}
+
+	encoded := recoveryEncoding.EncodeToString(raw)
+	groups := make([]string, 0, len(encoded)/recoveryCodeGroup)
+	for i := 0; i < len(encoded); i += recoveryCodeGroup {
+		groups = append(groups, encoded[i:i+recoveryCodeGroup])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// NormalizeRecoveryCode strips separators and case from a typed recovery code
+// in place and checks that it is well formed. The result is the secret used
+// for key derivation.
+func NormalizeRecoveryCode(code *Secret) error {
+	b := code.Bytes()
+	n := 0
+	for _, c := range b {
+		switch {
+		case c == '-' || c == ' ':
+			continue
+		case 'a' <= c && c <= 'z':
+			c -= 'a' - 'A'
+		}
+		b[n] = c
+		n++
+	}
+	code.truncate(n)
+
+	if n != recoveryEncoding.EncodedLen(recoveryCodeBytes) {
+		return fmt.Errorf("malformed recovery code")
+	}
+	raw := make([]byte, recoveryEncoding.DecodedLen(n))
+	defer Wipe(raw)
+	if decoded, err := recoveryEncoding.Decode(raw, code.Bytes()); err != nil || decoded != recoveryCodeBytes {
+		return fmt.Errorf("malformed recovery code")
+	}
+	return nil
+}
+
+// RecoveryKDFParams returns Argon2id parameters for recovery codes. Codes
+// carry 160 bits of entropy, so a light work factor is enough and keeps
+// trying every recovery keyslot fast.
+func RecoveryKDFParams() (KDFParams, error) {
+	params, err := DefaultKDFParams(KDFArgon2id)
+	if err != nil {
+		return KDFParams{}, err
+	}
+	params.Iterations = 1
+	params.Memory = 8 * 1024
+	params.Parallelism = 1
+	return params, nil
+}
@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCode returned error: %v", err)
+	}
+
+	groups := strings.Split(code, "-")
+	if len(groups) != 8 {
+		t.Errorf("Expected 8 groups, got %d (%s)", len(groups), code)
+	}
+	for _, group := range groups {
+		if len(group) != 4 {
+			t.Errorf("Expected groups of 4 characters, got %q", group)
+		}
+	}
+
+	other, _ := GenerateRecoveryCode()
+	if code == other {
+		t.Error("GenerateRecoveryCode produced the same code twice")
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	code, _ := GenerateRecoveryCode()
+	want := strings.ReplaceAll(code, "-", "")
+
+	inputs := []string{
+		code,
+		strings.ToLower(code),
+		strings.ReplaceAll(code, "-", " "),
+		want,
+	}
+	for _, input := range inputs {
+		secret := SecretFromString(input)
+		if err := NormalizeRecoveryCode(secret); err != nil {
+			t.Errorf("NormalizeRecoveryCode(%q) returned error: %v", input, err)
+		} else if got := string(secret.Bytes()); got != want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", input, got, want)
+		}
+		secret.Wipe()
+	}
+
+	for _, bad := range []string{"", "ABCD-EFGH", code + "A", "11111111"} {
+		secret := SecretFromString(bad)
+		if err := NormalizeRecoveryCode(secret); err == nil {
+			t.Errorf("NormalizeRecoveryCode(%q) should fail", bad)
+		}
+		secret.Wipe()
+	}
+}
+
+func TestRecoveryKDFParams(t *testing.T) {
+	params, err := RecoveryKDFParams()
+	if err != nil {
+		t.Fatalf("RecoveryKDFParams returned error: %v", err)
+	}
+	if err := params.Validate(); err != nil {
+		t.Errorf("RecoveryKDFParams are not valid: %v", err)
+	}
+}
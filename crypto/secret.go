@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"crypto/subtle"
+)
+
+// Secret holds sensitive bytes such as a master password or a data key.
+// Where the platform allows it the bytes live outside the Go heap, in memory
+// that is locked against being swapped out, so the garbage collector never
+// leaves copies behind. Wipe overwrites and releases them.
+//
+// Slices returned by Bytes must not be used after Wipe. A nil *Secret
+// behaves like an empty one.
+type Secret struct {
+	buf    []byte
+	mapped bool
+}
+
+// NewSecret moves b into a new Secret and zeroes b
+func NewSecret(b []byte) *Secret {
+	s := &Secret{}
+	s.buf, s.mapped = allocSecret(len(b))
+	copy(s.buf, b)
+	Wipe(b)
+	return s
+}
+
+// SecretFromString copies str into a new Secret. The string itself cannot be
+// wiped, so prefer NewSecret for anything read from the user.
+func SecretFromString(str string) *Secret {
+	s := &Secret{}
+	s.buf, s.mapped = allocSecret(len(str))
+	copy(s.buf, str)
+	return s
+}
+
+// Bytes returns the secret's contents without copying them
+func (s *Secret) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.buf
+}
+
+// Len returns the length of the secret in bytes
+func (s *Secret) Len() int {
+	return len(s.Bytes())
+}
+
+// Equal reports in constant time whether both secrets hold the same bytes
+func (s *Secret) Equal(other *Secret) bool {
+	return subtle.ConstantTimeCompare(s.Bytes(), other.Bytes()) == 1
+}
+
+// Wipe zeroes the secret and releases its memory. It is safe to call more
+// than once.
+func (s *Secret) Wipe() {
+	if s == nil || s.buf == nil {
+		return
+	}
+	// truncate may have shortened buf; release all of it
+	full := s.buf[:cap(s.buf)]
+	Wipe(full)
+	freeSecret(full, s.mapped)
+	s.buf = nil
+	s.mapped = false
+}
+
+// truncate shortens the secret to its first n bytes and zeroes the rest
+func (s *Secret) truncate(n int) {
+	Wipe(s.buf[n:])
+	s.buf = s.buf[:n]
+}
+
+// Wipe overwrites b with zeros
+func Wipe(b []byte) {
+	clear(b)
+}
@@ -0,0 +1,31 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package crypto
+
+import (
+	"syscall"
+)
+
+// allocSecret maps n bytes of anonymous memory and locks it into RAM. When
+// RLIMIT_MEMLOCK does not allow locking the mapping is still used, which at
+// least keeps it out of the Go heap.
+func allocSecret(n int) ([]byte, bool) {
+	if n == 0 {
+		return nil, false
+	}
+	buf, err := syscall.Mmap(-1, 0, n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, n), false
+	}
+	syscall.Mlock(buf)
+	return buf, true
+}
+
+// freeSecret unlocks and unmaps memory returned by allocSecret
+func freeSecret(buf []byte, mapped bool) {
+	if !mapped {
+		return
+	}
+	syscall.Munlock(buf)
+	syscall.Munmap(buf)
+}
@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd)
+
+package crypto
+
+// allocSecret allocates n bytes on the Go heap; this platform offers no
+// portable way to lock memory
+func allocSecret(n int) ([]byte, bool) {
+	if n == 0 {
+		return nil, false
+	}
+	return make([]byte, n), false
+}
+
+// freeSecret is a no-op; Wipe has already zeroed the buffer
+func freeSecret(buf []byte, mapped bool) {}
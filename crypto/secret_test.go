@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewSecret(t *testing.T) {
+	input := []byte("correct horse battery staple")
+	secret := NewSecret(input)
+	defer secret.Wipe()
+
+	if string(secret.Bytes()) != "correct horse battery staple" {
+		t.Errorf("Secret contents mismatch: got %q", secret.Bytes())
+	}
+	if !bytes.Equal(input, make([]byte, len(input))) {
+		t.Error("NewSecret should zero its input")
+	}
+	if secret.Len() != len(input) {
+		t.Errorf("Expected length %d, got %d", len(input), secret.Len())
+	}
+}
+
+func TestSecret_Equal(t *testing.T) {
+	a := SecretFromString("password")
+	b := SecretFromString("password")
+	c := SecretFromString("password2")
+	defer a.Wipe()
+	defer b.Wipe()
+	defer c.Wipe()
+
+	if !a.Equal(b) {
+		t.Error("Equal secrets should compare equal")
+	}
+	if a.Equal(c) {
+		t.Error("Different secrets should not compare equal")
+	}
+
+	var empty *Secret
+	if !empty.Equal(SecretFromString("")) {
+		t.Error("A nil secret should equal an empty one")
+	}
+}
+
+func TestSecret_Wipe(t *testing.T) {
+	secret := SecretFromString("password")
+	secret.Wipe()
+
+	if secret.Bytes() != nil || secret.Len() != 0 {
+		t.Error("Wiped secret should be empty")
+	}
+
+	// Wiping twice, or a nil secret, must be harmless
+	secret.Wipe()
+	var empty *Secret
+	empty.Wipe()
+}
+
+func TestSecret_Truncate(t *testing.T) {
+	secret := SecretFromString("password")
+	full := secret.Bytes()
+	secret.truncate(4)
+
+	if string(secret.Bytes()) != "pass" || secret.Len() != 4 {
+		t.Errorf("Expected %q, got %q", "pass", secret.Bytes())
+	}
+	if !bytes.Equal(full[4:], make([]byte, 4)) {
+		t.Error("truncate should zero the bytes it drops")
+	}
+	// Wipe releases the whole buffer, not just the truncated length
+	secret.Wipe()
+	if secret.Len() != 0 {
+		t.Error("Wiped secret should be empty")
+	}
+}
@@ -3,7 +3,9 @@ module passwordmanager
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/spf13/cobra v1.8.0
+	golang.org/x/crypto v0.17.0
 	golang.org/x/term v0.15.0
 )
 
@@ -14,17 +14,69 @@ type PasswordEntry struct {
 	Notes       string    `json:"notes"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Folder is the folder the entry is filed in, such as "work/dev", or
+	// empty for the top level. Nested folders are separated by slashes.
+	Folder string `json:"folder,omitempty"`
+
+	// Tags are free-form labels such as "work" or "2fa"
+	Tags []string `json:"tags,omitempty"`
+
+	// History holds earlier versions of the entry, oldest first
+	History []EntryRevision `json:"history,omitempty"`
 }
 
-// PasswordVault represents the encrypted vault containing all password entries
+// EntryRevision is an earlier version of a PasswordEntry, kept when the
+// entry is updated. Number counts the versions of the entry from 1 and is
+// not reused when old revisions are dropped. UpdatedAt is when this version
+// was saved.
+type EntryRevision struct {
+	Number    int       `json:"number"`
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
+	URL       string    `json:"url"`
+	Notes     string    `json:"notes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PasswordVault represents the encrypted vault containing all password entries.
+// Revision is incremented every time the vault is saved.
 type PasswordVault struct {
-	Entries []PasswordEntry `json:"entries"`
-	Version string          `json:"version"`
+	Entries  []PasswordEntry `json:"entries"`
+	Version  string          `json:"version"`
+	Revision uint64          `json:"revision"`
+
+	// Trash holds deleted entries until they are restored or purged
+	Trash []TrashedEntry `json:"trash,omitempty"`
 }
 
-// User represents the user configuration
+// TrashedEntry is a deleted entry and when it was deleted
+type TrashedEntry struct {
+	PasswordEntry
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// User represents the user configuration. The master password is verified
+// by opening the vault, so nothing derived from it is stored here.
 type User struct {
-	MasterPasswordHash string `json:"master_password_hash"`
-	Salt               string `json:"salt"`
-	CreatedAt          time.Time `json:"created_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+
+	// MasterPasswordHash and Salt are only set in user.dat files written by
+	// older versions and are removed by Storage.UpgradeUser
+	MasterPasswordHash string `json:"master_password_hash,omitempty"`
+	Salt               string `json:"salt,omitempty"`
+
+	// VaultBinding ties user.dat to one vault file
+	VaultBinding *VaultBinding `json:"vault_binding,omitempty"`
+}
+
+// VaultBinding records the ID of the vault that belongs to this user and
+// the newest generation of it that was saved, so that a vault file from
+// elsewhere or an older copy of this one is refused. MAC is keyed from the
+// vault data key, so it can only be produced by someone who can unlock it.
+type VaultBinding struct {
+	VaultID    string `json:"vault_id"`
+	Generation uint64 `json:"generation"`
+	MAC        string `json:"mac"`
 }
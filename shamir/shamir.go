@@ -0,0 +1,176 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8).
+//
+// Each byte of the secret is the constant term of its own random polynomial
+// of degree threshold-1. A share is the x coordinate followed by the value
+// of every polynomial at x. Any threshold shares reconstruct the secret by
+// Lagrange interpolation at zero; fewer reveal nothing about it.
+package shamir
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Split divides secret into parts shares, any threshold of which can
+// reconstruct it. Each share is len(secret)+1 bytes long.
+func Split(secret []byte, parts, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("cannot split an empty secret")
+	}
+	if threshold < 2 {
+		return nil, fmt.Errorf("threshold must be at least 2")
+	}
+	if parts < threshold {
+		return nil, fmt.Errorf("number of shares cannot be less than the threshold")
+	}
+	if parts > 255 {
+		return nil, fmt.Errorf("number of shares cannot exceed 255")
+	}
+
+	shares := make([][]byte, parts)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	for b, value := range secret {
+		coefficients[0] = value
+		if _, err := io.ReadFull(rand.Reader, coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			share[b+1] = evaluate(coefficients, share[0])
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine reconstructs the secret from shares produced by Split. It needs at
+// least threshold distinct shares; with fewer the result is meaningless.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("at least two shares are required")
+	}
+
+	length := len(shares[0])
+	if length < 2 {
+		return nil, fmt.Errorf("share too short")
+	}
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) != length {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+		if share[0] == 0 {
+			return nil, fmt.Errorf("invalid share index 0")
+		}
+		if seen[share[0]] {
+			return nil, fmt.Errorf("duplicate share %d", share[0])
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, length-1)
+	for b := range secret {
+		var value byte
+		for i, si := range shares {
+			// Lagrange basis polynomial for share i evaluated at zero
+			basis := byte(1)
+			for j, sj := range shares {
+				if i == j {
+					continue
+				}
+				basis = mul(basis, div(sj[0], add(sj[0], si[0])))
+			}
+			value = add(value, mul(si[b+1], basis))
+		}
+		secret[b] = value
+	}
+
+	return secret, nil
+}
+
+// FormatShare encodes a share and the threshold needed to use it as dash
+// separated groups of hex digits suitable for printing
+func FormatShare(threshold int, share []byte) string {
+	encoded := hex.EncodeToString(append([]byte{byte(threshold)}, share...))
+	groups := make([]string, 0, len(encoded)/4+1)
+	for i := 0; i < len(encoded); i += 4 {
+		end := i + 4
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		groups = append(groups, encoded[i:end])
+	}
+	return strings.Join(groups, "-")
+}
+
+// ParseShare decodes a share written by FormatShare, ignoring case, dashes
+// and spaces, and returns the threshold and the share
+func ParseShare(text string) (int, []byte, error) {
+	cleaned := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(text))
+	raw, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return 0, nil, fmt.Errorf("share is not valid hex: %w", err)
+	}
+	if len(raw) < 3 {
+		return 0, nil, fmt.Errorf("share too short")
+	}
+	threshold := int(raw[0])
+	if threshold < 2 {
+		return 0, nil, fmt.Errorf("invalid threshold %d in share", threshold)
+	}
+	return threshold, raw[1:], nil
+}
+
+// evaluate computes the polynomial with the given coefficients at x
+func evaluate(coefficients []byte, x byte) byte {
+	// Horner's method, highest degree first
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = add(mul(result, x), coefficients[i])
+	}
+	return result
+}
+
+// add returns a + b in GF(2^8)
+func add(a, b byte) byte {
+	return a ^ b
+}
+
+// mul returns a * b in GF(2^8) modulo the AES polynomial x^8+x^4+x^3+x+1.
+// It runs in constant time with respect to its inputs.
+func mul(a, b byte) byte {
+	var result byte
+	for i := 0; i < 8; i++ {
+		result ^= a & -(b & 1)
+		carry := -(a >> 7)
+		a = (a << 1) ^ (0x1b & carry)
+		b >>= 1
+	}
+	return result
+}
+
+// inverse returns the multiplicative inverse of a, which is a^254 since
+// every non-zero element satisfies a^255 = 1
+func inverse(a byte) byte {
+	result := byte(1)
+	power := a
+	for e := 254; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result = mul(result, power)
+		}
+		power = mul(power, power)
+	}
+	return result
+}
+
+// div returns a / b in GF(2^8); b must be non-zero
+func div(a, b byte) byte {
+	return mul(a, inverse(b))
+}
@@ -0,0 +1,141 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMulInverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if got := mul(byte(a), inverse(byte(a))); got != 1 {
+			t.Fatalf("%d * inverse(%d) = %d, want 1", a, a, got)
+		}
+	}
+
+	// Known AES field product
+	if got := mul(0x57, 0x83); got != 0xc1 {
+		t.Errorf("mul(0x57, 0x83) = %#x, want 0xc1", got)
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	tests := []struct {
+		name      string
+		parts     int
+		threshold int
+		use       []int
+	}{
+		{"2 of 2", 2, 2, []int{0, 1}},
+		{"2 of 3 first two", 3, 2, []int{0, 1}},
+		{"2 of 3 last two", 3, 2, []int{1, 2}},
+		{"3 of 5 any order", 5, 3, []int{4, 0, 2}},
+		{"3 of 5 with extra share", 5, 3, []int{0, 1, 2, 3}},
+		{"5 of 5", 5, 5, []int{0, 1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := Split(secret, tt.parts, tt.threshold)
+			if err != nil {
+				t.Fatalf("Split returned error: %v", err)
+			}
+			if len(shares) != tt.parts {
+				t.Fatalf("Expected %d shares, got %d", tt.parts, len(shares))
+			}
+
+			subset := make([][]byte, 0, len(tt.use))
+			for _, i := range tt.use {
+				subset = append(subset, shares[i])
+			}
+
+			recovered, err := Combine(subset)
+			if err != nil {
+				t.Fatalf("Combine returned error: %v", err)
+			}
+			if !bytes.Equal(recovered, secret) {
+				t.Errorf("Combine = %q, want %q", recovered, secret)
+			}
+		})
+	}
+}
+
+func TestCombine_BelowThreshold(t *testing.T) {
+	secret := []byte("top secret value")
+	shares, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("Split returned error: %v", err)
+	}
+
+	recovered, err := Combine(shares[:2])
+	if err != nil {
+		t.Fatalf("Combine returned error: %v", err)
+	}
+	if bytes.Equal(recovered, secret) {
+		t.Error("Two shares of a 3-of-5 split should not reveal the secret")
+	}
+}
+
+func TestSplit_InvalidArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    []byte
+		parts     int
+		threshold int
+	}{
+		{"empty secret", nil, 3, 2},
+		{"threshold one", []byte("x"), 3, 1},
+		{"parts below threshold", []byte("x"), 2, 3},
+		{"too many parts", []byte("x"), 256, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Split(tt.secret, tt.parts, tt.threshold); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestCombine_InvalidShares(t *testing.T) {
+	shares, _ := Split([]byte("secret"), 3, 2)
+
+	if _, err := Combine(shares[:1]); err == nil {
+		t.Error("Combine should require at least two shares")
+	}
+	if _, err := Combine([][]byte{shares[0], shares[0]}); err == nil {
+		t.Error("Combine should reject duplicate shares")
+	}
+	if _, err := Combine([][]byte{shares[0], shares[1][:3]}); err == nil {
+		t.Error("Combine should reject shares of different lengths")
+	}
+}
+
+func TestFormatParseShare(t *testing.T) {
+	shares, _ := Split([]byte("0123456789abcdef"), 3, 2)
+
+	text := FormatShare(2, shares[1])
+	threshold, share, err := ParseShare(text)
+	if err != nil {
+		t.Fatalf("ParseShare returned error: %v", err)
+	}
+	if threshold != 2 {
+		t.Errorf("Expected threshold 2, got %d", threshold)
+	}
+	if !bytes.Equal(share, shares[1]) {
+		t.Errorf("ParseShare = %x, want %x", share, shares[1])
+	}
+
+	// Case and separators are ignored
+	if _, again, err := ParseShare(" " + text + " "); err != nil || !bytes.Equal(again, share) {
+		t.Errorf("ParseShare should ignore surrounding spaces: %v", err)
+	}
+
+	for _, bad := range []string{"", "zz", "01-02", "0101"} {
+		if _, _, err := ParseShare(bad); err == nil {
+			t.Errorf("ParseShare(%q) should fail", bad)
+		}
+	}
+}
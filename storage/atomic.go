@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// writeFileAtomic replaces path with data so that readers, and the file
+// system after a crash, see either the old contents or the new contents but
+// never a partial write. The data is written to a temporary file in the same
+// directory, synced, and then renamed over path; the directory is synced last
+// so the rename itself survives a crash.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, tempPrefix(path)+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file on any failure before the rename
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	committed = true
+
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// tempPrefix returns the name prefix of writeFileAtomic's temporary files
+// for path
+func tempPrefix(path string) string {
+	return "." + filepath.Base(path) + ".tmp-"
+}
+
+// leftoverTempFiles returns the temporary files that interrupted
+// writeFileAtomic calls left next to the blob name, newest first. Only
+// FileBackend writes such files; other backends report none.
+func leftoverTempFiles(backend Backend, name string) ([]BlobInfo, error) {
+	temps, err := backend.List(path.Join(path.Dir(name), tempPrefix(name)))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(temps, func(i, j int) bool {
+		return temps[i].ModTime.After(temps[j].ModTime)
+	})
+	return temps, nil
+}
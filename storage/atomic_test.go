@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+)
+
+// saveGenerations saves one vault per title in turn and returns the bytes
+// of vault.dat after each save
+func saveGenerations(t *testing.T, dir string, titles ...string) [][]byte {
+	t.Helper()
+
+	store := NewStorage(dir)
+	store.Initialize()
+
+	var files [][]byte
+	for _, title := range titles {
+		vault := &models.PasswordVault{
+			Entries: []models.PasswordEntry{{ID: title, Title: title}},
+			Version: "1.0",
+		}
+		if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, VaultFileName))
+		if err != nil {
+			t.Fatalf("Failed to read vault: %v", err)
+		}
+		files = append(files, data)
+	}
+	return files
+}
+
+func loadTitle(t *testing.T, dir string) string {
+	t.Helper()
+
+	vault, err := NewStorage(dir).LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if len(vault.Entries) != 1 {
+		t.Fatalf("Unexpected vault contents: %+v", vault.Entries)
+	}
+	return vault.Entries[0].Title
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	path := filepath.Join(tempDir, "file.dat")
+	for _, contents := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("writeFileAtomic failed: %v", err)
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != contents {
+			t.Errorf("Expected %q, got %q", contents, data)
+		}
+	}
+
+	if temps, _ := leftoverTempFiles(NewFileBackend(tempDir), "file.dat"); len(temps) != 0 {
+		t.Errorf("Temporary files left behind: %v", temps)
+	}
+
+	// A write that cannot complete leaves nothing behind
+	missing := filepath.Join(tempDir, "missing", "file.dat")
+	if err := writeFileAtomic(missing, []byte("data"), 0600); err == nil {
+		t.Error("writeFileAtomic should fail when the directory does not exist")
+	}
+}
+
+func TestLoadVault_RemovesTruncatedTempFile(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	files := saveGenerations(t, tempDir, "first", "second")
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+
+	// Crash while writing the second save: vault.dat still holds the first,
+	// the temporary file only part of the second
+	os.WriteFile(vaultPath, files[0], 0600)
+	tmpPath := filepath.Join(tempDir, tempPrefix(vaultPath)+"1")
+	os.WriteFile(tmpPath, files[1][:len(files[1])/2], 0600)
+
+	if title := loadTitle(t, tempDir); title != "first" {
+		t.Errorf("Expected the first save, got %q", title)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Error("Truncated temporary file should be removed")
+	}
+}
+
+func TestLoadVault_CompletesInterruptedSave(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	files := saveGenerations(t, tempDir, "first", "second")
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+
+	// Crash after the second save was synced but before the rename
+	os.WriteFile(vaultPath, files[0], 0600)
+	os.WriteFile(filepath.Join(tempDir, tempPrefix(vaultPath)+"1"), files[1], 0600)
+
+	if title := loadTitle(t, tempDir); title != "second" {
+		t.Errorf("Expected the second save, got %q", title)
+	}
+	data, _ := os.ReadFile(vaultPath)
+	if !bytes.Equal(data, files[1]) {
+		t.Error("vault.dat should hold the completed save")
+	}
+	if temps, _ := leftoverTempFiles(NewFileBackend(tempDir), VaultFileName); len(temps) != 0 {
+		t.Errorf("Temporary files left behind: %v", temps)
+	}
+}
+
+func TestLoadVault_RestoresMissingVaultFromTempFile(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	files := saveGenerations(t, tempDir, "first")
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+
+	// Crash during the very first save, before vault.dat existed
+	os.Remove(vaultPath)
+	os.WriteFile(filepath.Join(tempDir, tempPrefix(vaultPath)+"1"), files[0], 0600)
+
+	if title := loadTitle(t, tempDir); title != "first" {
+		t.Errorf("Expected the first save, got %q", title)
+	}
+	if !NewStorage(tempDir).VaultExists() {
+		t.Error("vault.dat should have been restored")
+	}
+}
+
+func TestLoadVault_DiscardsStaleTempFile(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	files := saveGenerations(t, tempDir, "first", "second")
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+	tmpPath := filepath.Join(tempDir, tempPrefix(vaultPath)+"1")
+	os.WriteFile(tmpPath, files[0], 0600)
+
+	if title := loadTitle(t, tempDir); title != "second" {
+		t.Errorf("Expected the second save, got %q", title)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Error("Outdated temporary file should be removed")
+	}
+}
+
+func TestLoadVault_KeepsTempFileForWrongPassword(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	files := saveGenerations(t, tempDir, "first", "second")
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+	os.WriteFile(vaultPath, files[0], 0600)
+	tmpPath := filepath.Join(tempDir, tempPrefix(vaultPath)+"1")
+	os.WriteFile(tmpPath, files[1], 0600)
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("wrong")); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Expected ErrInvalidPassword, got %v", err)
+	}
+	if _, err := os.Stat(tmpPath); err != nil {
+		t.Error("Temporary file should be kept until the right password is given")
+	}
+
+	if title := loadTitle(t, tempDir); title != "second" {
+		t.Errorf("Expected the second save, got %q", title)
+	}
+}
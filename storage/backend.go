@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backend names accepted by OpenBackend
+const (
+	BackendFile = "file"
+	BackendKV   = "kv"
+)
+
+// ErrBlobNotFound is returned by a Backend for a blob that does not exist
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob. Revision changes every time the blob is
+// written, so two equal revisions mean the contents have not changed.
+type BlobInfo struct {
+	Name     string
+	Revision uint64
+	ModTime  time.Time
+	Size     int64
+}
+
+// Backend stores the named blobs a Storage is made of, such as vault.dat,
+// user.dat and backups/vault-1.dat. Names are relative and use forward
+// slashes. Put replaces a blob atomically: a reader, or the backend after a
+// crash, sees either the old or the new contents. Backends are not expected
+// to serialize writers from different processes; Storage does that with its
+// lock.
+type Backend interface {
+	// Get returns the contents of a blob, or ErrBlobNotFound
+	Get(name string) ([]byte, BlobInfo, error)
+
+	// Stat returns the metadata of a blob, or ErrBlobNotFound
+	Stat(name string) (BlobInfo, error)
+
+	// Put creates or replaces a blob
+	Put(name string, data []byte) (BlobInfo, error)
+
+	// List returns the blobs whose names start with prefix, sorted by name
+	List(prefix string) ([]BlobInfo, error)
+
+	// Delete removes a blob, or returns ErrBlobNotFound
+	Delete(name string) error
+}
+
+// OpenBackend opens the backend of the given kind for the data directory
+// dataDir. An empty kind selects BackendFile.
+func OpenBackend(kind, dataDir string) (Backend, error) {
+	switch kind {
+	case "", BackendFile:
+		return NewFileBackend(dataDir), nil
+	case BackendKV:
+		return NewKVBackend(filepath.Join(dataDir, KVFileName)), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", kind)
+	}
+}
+
+// checkBlobName rejects names that are empty, absolute or would escape the
+// backend's root
+func checkBlobName(name string) error {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name ||
+		name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, "\\") {
+		return fmt.Errorf("invalid blob name: %q", name)
+	}
+	return nil
+}
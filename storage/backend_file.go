@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileBackend stores each blob as a file below a directory. Blobs are
+// replaced with writeFileAtomic, so a crash can leave temporary files,
+// named with tempPrefix, next to the blob being written; List reports them
+// like any other file.
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend creates a backend storing blobs below dir
+func NewFileBackend(dir string) *FileBackend {
+	return &FileBackend{dir: dir}
+}
+
+// path returns the file a blob is stored in
+func (b *FileBackend) path(name string) (string, error) {
+	if err := checkBlobName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.dir, filepath.FromSlash(name)), nil
+}
+
+// Get returns the contents of a blob
+func (b *FileBackend) Get(name string) ([]byte, BlobInfo, error) {
+	p, err := b.path(name)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, BlobInfo{}, fileError(err)
+	}
+	info, err := b.Stat(name)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	return data, info, nil
+}
+
+// Stat returns the metadata of a blob. The revision is the modification
+// time in nanoseconds.
+func (b *FileBackend) Stat(name string) (BlobInfo, error) {
+	p, err := b.path(name)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return BlobInfo{}, fileError(err)
+	}
+	if info.IsDir() {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	return fileBlobInfo(name, info), nil
+}
+
+// Put creates or replaces a blob, creating its directory if needed
+func (b *FileBackend) Put(name string, data []byte) (BlobInfo, error) {
+	p, err := b.path(name)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := writeFileAtomic(p, data, 0600); err != nil {
+		return BlobInfo{}, err
+	}
+	return b.Stat(name)
+}
+
+// List returns the files whose names start with prefix. Only the directory
+// named by the part of prefix up to its last slash is searched.
+func (b *FileBackend) List(prefix string) ([]BlobInfo, error) {
+	dir, base := path.Split(prefix)
+	if dir != "" {
+		if err := checkBlobName(strings.TrimSuffix(dir, "/")); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(b.dir, filepath.FromSlash(dir)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var blobs []BlobInfo
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), base) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		blobs = append(blobs, fileBlobInfo(dir+entry.Name(), info))
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].Name < blobs[j].Name
+	})
+	return blobs, nil
+}
+
+// Delete removes a blob
+func (b *FileBackend) Delete(name string) error {
+	p, err := b.path(name)
+	if err != nil {
+		return err
+	}
+	return fileError(os.Remove(p))
+}
+
+// fileBlobInfo describes the file holding a blob
+func fileBlobInfo(name string, info fs.FileInfo) BlobInfo {
+	return BlobInfo{
+		Name:     name,
+		Revision: uint64(info.ModTime().UnixNano()),
+		ModTime:  info.ModTime(),
+		Size:     info.Size(),
+	}
+}
+
+// fileError reports a missing file as ErrBlobNotFound
+func fileError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrBlobNotFound
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KVFileName is the file KVBackend keeps in the data directory
+const KVFileName = "pm.db"
+
+// kvMagic identifies a KVBackend file
+var kvMagic = []byte("PMSTORE\x00")
+
+// kvFormatVersion is the version of the KVBackend file layout
+const kvFormatVersion uint16 = 1
+
+// ErrStoreCorrupted is returned when a KVBackend file fails its checksum or
+// cannot be parsed
+var ErrStoreCorrupted = errors.New("key-value store is corrupted")
+
+// KVBackend keeps every blob in a single file:
+//
+//	magic(8) | version(2) | revision(8) | count(4) | records... | sha256(32)
+//
+// where revision is the last revision Put handed out and each record is
+// name length(2) | name | revision(8) | modification time in Unix
+// nanoseconds(8) | data length(4) | data. Every write rewrites the whole
+// file with writeFileAtomic, so the file is never left half-written.
+// Keeping the counter in the header rather than deriving it from the
+// records means a revision is never reused, even after the blob that held
+// the highest one is deleted.
+type KVBackend struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewKVBackend creates a backend storing blobs in the file at path
+func NewKVBackend(path string) *KVBackend {
+	return &KVBackend{path: path}
+}
+
+// Get returns the contents of a blob
+func (b *KVBackend) Get(name string) ([]byte, BlobInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blobs, _, err := b.load()
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	blob, ok := blobs[name]
+	if !ok {
+		return nil, BlobInfo{}, ErrBlobNotFound
+	}
+	return blob.data, blob.info, nil
+}
+
+// Stat returns the metadata of a blob
+func (b *KVBackend) Stat(name string) (BlobInfo, error) {
+	_, info, err := b.Get(name)
+	return info, err
+}
+
+// Put creates or replaces a blob
+func (b *KVBackend) Put(name string, data []byte) (BlobInfo, error) {
+	if err := checkBlobName(name); err != nil {
+		return BlobInfo{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blobs, revision, err := b.load()
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	revision++
+	info := BlobInfo{
+		Name:     name,
+		Revision: revision,
+		ModTime:  time.Now(),
+		Size:     int64(len(data)),
+	}
+	blobs[name] = blobRecord{data: append([]byte(nil), data...), info: info}
+	if err := b.store(blobs, revision); err != nil {
+		return BlobInfo{}, err
+	}
+	return info, nil
+}
+
+// List returns the blobs whose names start with prefix
+func (b *KVBackend) List(prefix string) ([]BlobInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blobs, _, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []BlobInfo
+	for name, blob := range blobs {
+		if strings.HasPrefix(name, prefix) {
+			infos = append(infos, blob.info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// Delete removes a blob
+func (b *KVBackend) Delete(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blobs, revision, err := b.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := blobs[name]; !ok {
+		return ErrBlobNotFound
+	}
+	delete(blobs, name)
+	return b.store(blobs, revision)
+}
+
+// load reads every blob from the file along with the revision counter. A
+// missing file holds no blobs.
+func (b *KVBackend) load() (map[string]blobRecord, uint64, error) {
+	blobs := make(map[string]blobRecord)
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return blobs, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to read %s: %w", filepath.Base(b.path), err)
+	}
+
+	if len(data) < len(kvMagic)+2+8+4+sha256.Size || !bytes.Equal(data[:len(kvMagic)], kvMagic) {
+		return nil, 0, ErrStoreCorrupted
+	}
+	body, sum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], sum) {
+		return nil, 0, ErrStoreCorrupted
+	}
+
+	r := bytes.NewReader(body[len(kvMagic):])
+	var version uint16
+	var revision uint64
+	var count uint32
+	binary.Read(r, binary.BigEndian, &version)
+	binary.Read(r, binary.BigEndian, &revision)
+	binary.Read(r, binary.BigEndian, &count)
+	if version != kvFormatVersion {
+		return nil, 0, fmt.Errorf("unsupported key-value store version %d", version)
+	}
+
+	for i := uint32(0); i < count; i++ {
+		var nameLen uint16
+		if err := binary.Read(r, binary.BigEndian, &nameLen); err != nil {
+			return nil, 0, ErrStoreCorrupted
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, 0, ErrStoreCorrupted
+		}
+
+		var record struct {
+			Revision uint64
+			ModTime  int64
+			DataLen  uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &record); err != nil || int64(record.DataLen) > int64(r.Len()) {
+			return nil, 0, ErrStoreCorrupted
+		}
+		blob := make([]byte, record.DataLen)
+		io.ReadFull(r, blob)
+
+		blobs[string(name)] = blobRecord{
+			data: blob,
+			info: BlobInfo{
+				Name:     string(name),
+				Revision: record.Revision,
+				ModTime:  time.Unix(0, record.ModTime),
+				Size:     int64(record.DataLen),
+			},
+		}
+	}
+	if r.Len() != 0 {
+		return nil, 0, ErrStoreCorrupted
+	}
+	return blobs, revision, nil
+}
+
+// store writes every blob and the revision counter to the file, replacing
+// it atomically
+func (b *KVBackend) store(blobs map[string]blobRecord, revision uint64) error {
+	names := make([]string, 0, len(blobs))
+	for name := range blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(kvMagic)
+	binary.Write(&buf, binary.BigEndian, kvFormatVersion)
+	binary.Write(&buf, binary.BigEndian, revision)
+	binary.Write(&buf, binary.BigEndian, uint32(len(names)))
+	for _, name := range names {
+		blob := blobs[name]
+		binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+		buf.WriteString(name)
+		binary.Write(&buf, binary.BigEndian, blob.info.Revision)
+		binary.Write(&buf, binary.BigEndian, blob.info.ModTime.UnixNano())
+		binary.Write(&buf, binary.BigEndian, uint32(len(blob.data)))
+		buf.Write(blob.data)
+	}
+	checksum := sha256.Sum256(buf.Bytes())
+	buf.Write(checksum[:])
+
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return writeFileAtomic(b.path, buf.Bytes(), 0600)
+}
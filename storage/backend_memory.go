@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps blobs in memory. It is meant for tests and for
+// callers that never need the vault to outlive the process.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	blobs    map[string]blobRecord
+	revision uint64
+}
+
+// blobRecord is a blob held by MemoryBackend or KVBackend
+type blobRecord struct {
+	data []byte
+	info BlobInfo
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{blobs: make(map[string]blobRecord)}
+}
+
+// Get returns a copy of the contents of a blob
+func (b *MemoryBackend) Get(name string) ([]byte, BlobInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blob, ok := b.blobs[name]
+	if !ok {
+		return nil, BlobInfo{}, ErrBlobNotFound
+	}
+	return append([]byte(nil), blob.data...), blob.info, nil
+}
+
+// Stat returns the metadata of a blob
+func (b *MemoryBackend) Stat(name string) (BlobInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blob, ok := b.blobs[name]
+	if !ok {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	return blob.info, nil
+}
+
+// Put stores a copy of data
+func (b *MemoryBackend) Put(name string, data []byte) (BlobInfo, error) {
+	if err := checkBlobName(name); err != nil {
+		return BlobInfo{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.revision++
+	info := BlobInfo{
+		Name:     name,
+		Revision: b.revision,
+		ModTime:  time.Now(),
+		Size:     int64(len(data)),
+	}
+	b.blobs[name] = blobRecord{data: append([]byte(nil), data...), info: info}
+	return info, nil
+}
+
+// List returns the blobs whose names start with prefix
+func (b *MemoryBackend) List(prefix string) ([]BlobInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var blobs []BlobInfo
+	for name, blob := range b.blobs {
+		if strings.HasPrefix(name, prefix) {
+			blobs = append(blobs, blob.info)
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].Name < blobs[j].Name
+	})
+	return blobs, nil
+}
+
+// Delete removes a blob
+func (b *MemoryBackend) Delete(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.blobs[name]; !ok {
+		return ErrBlobNotFound
+	}
+	delete(b.blobs, name)
+	return nil
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+)
+
+// testBackends returns one backend of every kind, each in its own directory
+func testBackends(t *testing.T, dir string) map[string]Backend {
+	return map[string]Backend{
+		"file":   NewFileBackend(filepath.Join(dir, "file")),
+		"kv":     NewKVBackend(filepath.Join(dir, "kv", KVFileName)),
+		"memory": NewMemoryBackend(),
+	}
+}
+
+func TestBackends_PutGetListDelete(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for kind, backend := range testBackends(t, tempDir) {
+		t.Run(kind, func(t *testing.T) {
+			if _, _, err := backend.Get(VaultFileName); !errors.Is(err, ErrBlobNotFound) {
+				t.Fatalf("Expected ErrBlobNotFound, got %v", err)
+			}
+
+			first, err := backend.Put(VaultFileName, []byte("first"))
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			second, err := backend.Put(VaultFileName, []byte("second"))
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if second.Revision == first.Revision {
+				t.Error("Put should change the revision")
+			}
+
+			data, info, err := backend.Get(VaultFileName)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if string(data) != "second" || info.Revision != second.Revision || info.Size != 6 {
+				t.Errorf("Unexpected blob %q %+v", data, info)
+			}
+
+			backend.Put("backups/vault-2.dat", []byte("two"))
+			backend.Put("backups/vault-1.dat", []byte("one"))
+			blobs, err := backend.List("backups/")
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(blobs) != 2 || blobs[0].Name != "backups/vault-1.dat" || blobs[1].Name != "backups/vault-2.dat" {
+				t.Errorf("Unexpected listing: %+v", blobs)
+			}
+
+			if err := backend.Delete("backups/vault-1.dat"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := backend.Delete("backups/vault-1.dat"); !errors.Is(err, ErrBlobNotFound) {
+				t.Errorf("Expected ErrBlobNotFound, got %v", err)
+			}
+			if _, err := backend.Stat("backups/vault-1.dat"); !errors.Is(err, ErrBlobNotFound) {
+				t.Errorf("Expected ErrBlobNotFound, got %v", err)
+			}
+
+			if _, err := backend.Put("../escape", []byte("data")); err == nil {
+				t.Error("Put should reject names outside the backend")
+			}
+		})
+	}
+}
+
+func TestBackends_StorageRoundTrip(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	for kind, backend := range testBackends(t, tempDir) {
+		t.Run(kind, func(t *testing.T) {
+			store := NewStorageWithBackend(backend, "")
+			vault := &models.PasswordVault{
+				Entries: []models.PasswordEntry{{ID: "1", Title: "Mail"}},
+				Version: "1.0",
+			}
+			for i := 0; i < 2; i++ {
+				if err := store.SaveVault(vault, password); err != nil {
+					t.Fatalf("SaveVault failed: %v", err)
+				}
+			}
+			if err := store.SaveUser(&models.User{}); err != nil {
+				t.Fatalf("SaveUser failed: %v", err)
+			}
+			if !store.UserExists() || !store.VaultExists() {
+				t.Fatal("User and vault should exist")
+			}
+
+			loaded, err := NewStorageWithBackend(backend, "").LoadVault(password)
+			if err != nil {
+				t.Fatalf("LoadVault failed: %v", err)
+			}
+			if len(loaded.Entries) != 1 || loaded.Entries[0].Title != "Mail" {
+				t.Errorf("Unexpected entries: %+v", loaded.Entries)
+			}
+
+			backups, err := store.ListBackups()
+			if err != nil || len(backups) != 1 || backups[0].ID != 1 {
+				t.Errorf("Unexpected backups: %+v, %v", backups, err)
+			}
+		})
+	}
+}
+
+func TestKVBackend_DetectsCorruption(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	path := filepath.Join(tempDir, KVFileName)
+	backend := NewKVBackend(path)
+	if _, err := backend.Put(UserFileName, []byte("{}")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	data[len(kvMagic)+8] ^= 0xff
+	os.WriteFile(path, data, 0600)
+
+	if _, _, err := backend.Get(UserFileName); !errors.Is(err, ErrStoreCorrupted) {
+		t.Errorf("Expected ErrStoreCorrupted, got %v", err)
+	}
+}
+
+func TestKVBackend_RevisionsNotReused(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	backend := NewKVBackend(filepath.Join(tempDir, KVFileName))
+	backend.Put(UserFileName, []byte("{}"))
+	deleted, err := backend.Put("backups/vault-1.dat", []byte("one"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := backend.Delete("backups/vault-1.dat"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// A new backend reads the counter back from the file
+	info, err := NewKVBackend(filepath.Join(tempDir, KVFileName)).Put(VaultFileName, []byte("vault"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if info.Revision <= deleted.Revision {
+		t.Errorf("Revision %d reused after deleting the blob with revision %d", info.Revision, deleted.Revision)
+	}
+}
+
+func TestOpenBackend(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if backend, err := OpenBackend("", tempDir); err != nil {
+		t.Errorf("OpenBackend failed: %v", err)
+	} else if _, ok := backend.(*FileBackend); !ok {
+		t.Errorf("Expected the file backend by default, got %T", backend)
+	}
+	if backend, err := OpenBackend(BackendKV, tempDir); err != nil {
+		t.Errorf("OpenBackend failed: %v", err)
+	} else if _, ok := backend.(*KVBackend); !ok {
+		t.Errorf("Expected the key-value backend, got %T", backend)
+	}
+	if _, err := OpenBackend("cloud", tempDir); err == nil {
+		t.Error("OpenBackend should reject unknown backends")
+	}
+}
@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupDirName is the directory, within the backend, holding backups
+const BackupDirName = "backups"
+
+// DefaultBackupKeep is how many backups are kept unless configured otherwise
+const DefaultBackupKeep = 10
+
+// ErrBackupNotFound is returned for a backup ID that does not exist
+var ErrBackupNotFound = errors.New("backup not found")
+
+// BackupPolicy controls which backups PruneBackups removes. Only the newest
+// Keep backups are kept, and of those only the ones younger than MaxAge. A
+// zero field disables that limit. The newest backup is never removed for
+// its age.
+type BackupPolicy struct {
+	Keep   int
+	MaxAge time.Duration
+}
+
+// Backup is an encrypted copy of an earlier vault.dat. Its ID is the
+// generation of the vault it holds and Name its blob name in the backend,
+// which records the vault ID and when the backup was made. OtherVault is
+// set for a backup of a different vault than vault.dat, such as one left
+// by running pm init again in the same directory; such backups are listed
+// but never pruned or rewritten.
+type Backup struct {
+	ID         uint64
+	Name       string
+	VaultID    []byte
+	CreatedAt  time.Time
+	Size       int64
+	OtherVault bool
+}
+
+// SetBackupPolicy sets the policy SaveVault prunes backups with
+func (s *Storage) SetBackupPolicy(policy BackupPolicy) {
+	s.backupPolicy = policy
+}
+
+// backupName returns the blob name of a backup of generation id of the
+// vault vaultID, made at created. The time is kept in the name because
+// rekeyBackups rewrites backups, which resets their modification time.
+func backupName(vaultID []byte, id uint64, created time.Time) string {
+	return path.Join(BackupDirName, fmt.Sprintf("vault-%x-%d-%d.dat", vaultID, id, created.Unix()))
+}
+
+// parseBackupName reads a blob name written by backupName
+func parseBackupName(name string) (Backup, bool) {
+	fields := strings.Split(strings.TrimSuffix(path.Base(name), ".dat"), "-")
+	if len(fields) != 4 || fields[0] != "vault" {
+		return Backup{}, false
+	}
+	vaultID, err := hex.DecodeString(fields[1])
+	if err != nil || len(vaultID) != VaultIDSize {
+		return Backup{}, false
+	}
+	id, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return Backup{}, false
+	}
+	created, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return Backup{}, false
+	}
+
+	backup := Backup{ID: id, Name: name, VaultID: vaultID, CreatedAt: time.Unix(created, 0)}
+	if name != backupName(vaultID, id, backup.CreatedAt) {
+		return Backup{}, false
+	}
+	return backup, true
+}
+
+// backupVault copies the current vault.dat, still encrypted, into the
+// backup directory before it is replaced. Files in formats that predate the
+// vault generation are not backed up.
+func (s *Storage) backupVault() error {
+	data, _, err := s.backend.Get(VaultFileName)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if detectFormat(data) < FormatBound {
+		return nil
+	}
+	header, _, _, err := parseVaultHeader(data)
+	if err != nil {
+		return err
+	}
+
+	// A save that failed after its backup was made may be retried
+	backups, err := s.ListBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if backup.ID == header.Generation && bytes.Equal(backup.VaultID, header.VaultID) {
+			return nil
+		}
+	}
+
+	_, err = s.backend.Put(backupName(header.VaultID, header.Generation, time.Now()), data)
+	return err
+}
+
+// rekeyBackups gives every backup the keyslots of the vault just saved.
+// Backups are copies of earlier vault files, so without this a replaced
+// master password or a used recovery code would still unwrap the data key
+// from them, and that key opens the current vault too. Each backup whose
+// keyslots differ is decrypted with the data key and sealed again under a
+// header carrying the current keyslots, keeping its name. Backups of other
+// vaults, and ones that cannot be decrypted, such as damaged backups or
+// ones written by a newer version, are left as they are: they may be the
+// only copy of something, and pm doctor reports them.
+func (s *Storage) rekeyBackups() error {
+	current, err := marshalKeyslots(s.keyslots)
+	if err != nil {
+		return err
+	}
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if backup.OtherVault {
+			continue
+		}
+		data, _, err := s.backend.Get(backup.Name)
+		if err != nil {
+			return fmt.Errorf("failed to read backup %d: %w", backup.ID, err)
+		}
+
+		header, headerBytes, ciphertext, err := parseVaultHeader(data)
+		if err != nil {
+			continue
+		}
+		if slots, err := marshalKeyslots(header.Keyslots); err == nil && bytes.Equal(slots, current) {
+			continue
+		}
+
+		rekeyed, err := s.rekeyBackup(header, headerBytes, ciphertext)
+		if err != nil {
+			continue
+		}
+		if _, err := s.backend.Put(backup.Name, rekeyed); err != nil {
+			return fmt.Errorf("failed to rewrite backup %d: %w", backup.ID, err)
+		}
+	}
+	return nil
+}
+
+// rekeyBackup decrypts a backup of this vault and seals its contents again
+// under the current keyslots, keeping its cipher suite and generation
+func (s *Storage) rekeyBackup(header *VaultHeader, headerBytes, ciphertext []byte) ([]byte, error) {
+	if header.FormatVersion < FormatBound || !bytes.Equal(header.VaultID, s.vaultID) {
+		return nil, fmt.Errorf("backup belongs to a different vault")
+	}
+	plaintext, err := header.CipherSuite.Open(s.dataKey.Bytes(), ciphertext, header.additionalData(headerBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Wipe(plaintext)
+
+	rekeyed := &VaultHeader{
+		FormatVersion: CurrentFormatVersion,
+		CipherSuite:   header.CipherSuite,
+		VaultID:       header.VaultID,
+		Generation:    header.Generation,
+		Keyslots:      s.keyslots,
+	}
+	rekeyedBytes, err := rekeyed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := rekeyed.CipherSuite.Seal(s.dataKey.Bytes(), plaintext, rekeyed.additionalData(rekeyedBytes))
+	if err != nil {
+		return nil, err
+	}
+	return append(rekeyedBytes, sealed...), nil
+}
+
+// marshalKeyslots encodes slots the way the vault header stores them
+func marshalKeyslots(slots []Keyslot) ([]byte, error) {
+	var buf []byte
+	for _, slot := range slots {
+		encoded, err := slot.marshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, encoded...)
+	}
+	return buf, nil
+}
+
+// ListBackups returns the available backups, newest first
+func (s *Storage) ListBackups() ([]Backup, error) {
+	blobs, err := s.backend.List(BackupDirName + "/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	current := s.currentVaultID()
+	var backups []Backup
+	for _, blob := range blobs {
+		backup, ok := parseBackupName(blob.Name)
+		if !ok {
+			continue
+		}
+		backup.Size = blob.Size
+		backup.OtherVault = current != nil && !bytes.Equal(backup.VaultID, current)
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].ID > backups[j].ID
+	})
+	return backups, nil
+}
+
+// currentVaultID returns the ID of the vault last read or written, else
+// the one in the header of vault.dat, or nil if neither is known
+func (s *Storage) currentVaultID() []byte {
+	if s.vaultID != nil {
+		return s.vaultID
+	}
+	if header, err := s.ReadVaultHeader(); err == nil {
+		return header.VaultID
+	}
+	return nil
+}
+
+// findBackup returns the backup of this vault with the given ID
+func (s *Storage) findBackup(id uint64) (Backup, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return Backup{}, err
+	}
+	for _, backup := range backups {
+		if backup.ID == id && !backup.OtherVault {
+			return backup, nil
+		}
+	}
+	return Backup{}, ErrBackupNotFound
+}
+
+// LoadBackup decrypts a backup with the data key of the unlocked vault
+func (s *Storage) LoadBackup(id uint64) (*models.PasswordVault, error) {
+	if s.dataKey == nil {
+		return nil, ErrVaultLocked
+	}
+
+	backup, err := s.findBackup(id)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := s.backend.Get(backup.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	header, headerBytes, ciphertext, err := parseVaultHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup header: %w", err)
+	}
+	if header.FormatVersion < FormatBound || string(header.VaultID) != string(s.vaultID) {
+		return nil, fmt.Errorf("backup %d belongs to a different vault", id)
+	}
+
+	plaintext, err := header.CipherSuite.Open(s.dataKey.Bytes(), ciphertext, header.additionalData(headerBytes))
+	if err != nil {
+		if errors.Is(err, crypto.ErrAuthFailed) {
+			return nil, ErrVaultCorrupted
+		}
+		return nil, fmt.Errorf("failed to decrypt backup: %w", err)
+	}
+	vault, _, err := decodeVault(plaintext)
+	return vault, err
+}
+
+// RestoreBackup replaces the contents of the unlocked vault, currently
+// current, with those of a backup and saves it. The unlock methods of the
+// current vault are kept, and the vault it replaces is backed up in turn.
+func (s *Storage) RestoreBackup(id uint64, current *models.PasswordVault) (*models.PasswordVault, error) {
+	restored, err := s.LoadBackup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Carry on from the current revision so concurrent sessions see a change
+	restored.Revision = current.Revision
+	if err := s.SaveVault(restored, nil); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PruneBackups removes the backups of this vault that policy does not keep
+// and returns them. Backups of other vaults are not counted or removed.
+func (s *Storage) PruneBackups(policy BackupPolicy) ([]Backup, error) {
+	if err := s.Lock(); err != nil {
+		return nil, err
+	}
+	defer s.Unlock()
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Backup
+	i := -1
+	for _, backup := range backups {
+		if backup.OtherVault {
+			continue
+		}
+		i++
+		tooMany := policy.Keep > 0 && i >= policy.Keep
+		tooOld := policy.MaxAge > 0 && i > 0 && time.Since(backup.CreatedAt) > policy.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := s.backend.Delete(backup.Name); err != nil {
+			return removed, fmt.Errorf("failed to remove backup %d: %w", backup.ID, err)
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
@@ -0,0 +1,389 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+	"time"
+)
+
+func TestSaveVault_KeepsBackups(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+	store.SetBackupPolicy(BackupPolicy{Keep: 2})
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	for i := 0; i < 4; i++ {
+		if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+	}
+
+	// Generations 1 to 3 were replaced; only the newest two are kept
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0].ID != 3 || backups[1].ID != 2 {
+		t.Fatalf("Unexpected backups: %+v", backups)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{
+		Entries: []models.PasswordEntry{{ID: "1", Title: "Mail"}},
+		Version: "1.0",
+	}
+	if err := store.SaveVault(vault, password); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	if err := store.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	// An accidental delete
+	vault.Entries = nil
+	if err := store.SaveVault(vault, password); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	backups, _ := store.ListBackups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected one backup, got %+v", backups)
+	}
+
+	session := NewStorage(tempDir)
+	current, err := session.LoadVault(password)
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	restored, err := session.RestoreBackup(backups[0].ID, current)
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if len(restored.Entries) != 1 || restored.Revision != current.Revision+1 {
+		t.Fatalf("Unexpected restored vault: %+v", restored)
+	}
+
+	// The restored vault is a new save, not a rollback
+	reopened, err := NewStorage(tempDir).LoadVault(password)
+	if err != nil {
+		t.Fatalf("LoadVault after restore failed: %v", err)
+	}
+	if len(reopened.Entries) != 1 || reopened.Entries[0].Title != "Mail" {
+		t.Errorf("Restore not saved: %+v", reopened.Entries)
+	}
+
+	if _, err := session.LoadBackup(999); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("Expected ErrBackupNotFound, got %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadBackup(backups[0].ID); !errors.Is(err, ErrVaultLocked) {
+		t.Errorf("Expected ErrVaultLocked, got %v", err)
+	}
+}
+
+func TestLoadBackup_RejectsOtherVault(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	dirs := []string{filepath.Join(tempDir, "a"), filepath.Join(tempDir, "b")}
+	stores := make([]*Storage, len(dirs))
+	for i, dir := range dirs {
+		stores[i] = NewStorage(dir)
+		stores[i].Initialize()
+		stores[i].SaveVault(vault, password)
+		stores[i].SaveVault(vault, password)
+	}
+
+	// Plant b's backup in a's backup directory, under a name claiming to be
+	// one of a's
+	backups, _ := stores[1].ListBackups()
+	data, _, _ := stores[1].backend.Get(backups[0].Name)
+	stores[0].backend.Put(backupName(stores[0].vaultID, 7, time.Now()), data)
+
+	if _, err := stores[0].LoadBackup(7); err == nil {
+		t.Error("LoadBackup should reject a backup of another vault")
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+	os.MkdirAll(filepath.Join(tempDir, BackupDirName), 0700)
+
+	// The age comes from the name, not the modification time
+	old := time.Now().Add(-48 * time.Hour)
+	vaultID := make([]byte, VaultIDSize)
+	for id := uint64(1); id <= 3; id++ {
+		store.backend.Put(backupName(vaultID, id, old), []byte("data"))
+	}
+	os.WriteFile(filepath.Join(tempDir, BackupDirName, "notes.txt"), []byte("data"), 0600)
+
+	removed, err := store.PruneBackups(BackupPolicy{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected two backups removed, got %+v", removed)
+	}
+
+	// The newest backup survives even though it is too old
+	backups, _ := store.ListBackups()
+	if len(backups) != 1 || backups[0].ID != 3 {
+		t.Errorf("Expected only backup 3 to remain, got %+v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, BackupDirName, "notes.txt")); err != nil {
+		t.Error("Unrelated files must not be removed")
+	}
+}
+
+// backupsAsVault puts each backup in place of vault.dat, the way someone
+// with a copy of the data directory could, and reports whether open got
+// past the keyslots of any of them. Later checks, such as the rollback
+// check, still fail, but by then the data key has been unwrapped.
+func backupsAsVault(t *testing.T, dir string, open func(store *Storage) error) bool {
+	t.Helper()
+	backups, err := NewStorage(dir).ListBackups()
+	if err != nil || len(backups) == 0 {
+		t.Fatalf("Expected backups, got %v (%v)", backups, err)
+	}
+	opened := false
+	for _, backup := range backups {
+		store := NewStorage(dir)
+		data, _, err := store.backend.Get(backup.Name)
+		if err != nil {
+			t.Fatalf("Failed to read backup %d: %v", backup.ID, err)
+		}
+		if _, err := store.backend.Put(VaultFileName, data); err != nil {
+			t.Fatalf("Failed to replace vault.dat: %v", err)
+		}
+		if err := open(store); !errors.Is(err, ErrInvalidPassword) {
+			opened = true
+		}
+	}
+	return opened
+}
+
+func TestChangeMasterPassword_OldPasswordCannotOpenBackups(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	oldPassword, newPassword := crypto.SecretFromString("old password"), crypto.SecretFromString("new password")
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{{ID: "1", Title: "Mail"}}, Version: "1.0"}
+	for i := 0; i < 3; i++ {
+		if err := store.SaveVault(vault, oldPassword); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+	}
+	if err := store.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+	if err := store.ChangeMasterPassword(vault, newPassword); err != nil {
+		t.Fatalf("ChangeMasterPassword failed: %v", err)
+	}
+
+	// Restoring a backup made before the change keeps the new password
+	backups, _ := store.ListBackups()
+	if _, err := store.RestoreBackup(backups[len(backups)-1].ID, vault); err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVault(oldPassword); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Old password should not open the restored vault, got %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVault(newPassword); err != nil {
+		t.Errorf("New password should open the restored vault: %v", err)
+	}
+
+	// No backup file unwraps the data key for the old password
+	if backupsAsVault(t, tempDir, func(s *Storage) error { _, err := s.LoadVault(oldPassword); return err }) {
+		t.Error("Old password opened a backup")
+	}
+	if !backupsAsVault(t, tempDir, func(s *Storage) error { _, err := s.LoadVault(newPassword); return err }) {
+		t.Error("New password should open the backups")
+	}
+}
+
+func TestRecoverMasterPassword_UsedCodeCannotOpenBackups(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("forgotten")
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, password); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	codes, err := store.GenerateRecoveryCodes(2)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.SaveVault(vault, password); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+	}
+
+	recovering := NewStorage(tempDir)
+	recovered, err := recovering.LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[0]))
+	if err != nil {
+		t.Fatalf("LoadVaultWithRecoveryCode failed: %v", err)
+	}
+	if err := recovering.RecoverMasterPassword(recovered, crypto.SecretFromString("new password")); err != nil {
+		t.Fatalf("RecoverMasterPassword failed: %v", err)
+	}
+
+	if backupsAsVault(t, tempDir, func(s *Storage) error { _, err := s.LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[0])); return err }) {
+		t.Error("A used recovery code opened a backup")
+	}
+	if !backupsAsVault(t, tempDir, func(s *Storage) error { _, err := s.LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[1])); return err }) {
+		t.Error("An unused recovery code should open the backups")
+	}
+}
+
+func TestChangeMasterPassword_KeepsBackupTimes(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	for i := 0; i < 3; i++ {
+		if err := store.SaveVault(vault, password); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+	}
+	if err := store.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	// Date the backups back by renaming them
+	old := time.Now().Add(-90 * 24 * time.Hour).Truncate(time.Second)
+	backups, _ := store.ListBackups()
+	for _, backup := range backups {
+		data, _, _ := store.backend.Get(backup.Name)
+		store.backend.Put(backupName(backup.VaultID, backup.ID, old), data)
+		store.backend.Delete(backup.Name)
+	}
+
+	if err := store.ChangeMasterPassword(vault, crypto.SecretFromString("new password")); err != nil {
+		t.Fatalf("ChangeMasterPassword failed: %v", err)
+	}
+
+	backups, _ = store.ListBackups()
+	if len(backups) != 3 {
+		t.Fatalf("Expected three backups, got %+v", backups)
+	}
+	for _, backup := range backups[1:] {
+		if !backup.CreatedAt.Equal(old) {
+			t.Errorf("Backup %d should still be from %s, got %s", backup.ID, old, backup.CreatedAt)
+		}
+	}
+
+	removed, err := store.PruneBackups(BackupPolicy{MaxAge: 30 * 24 * time.Hour})
+	if err != nil || len(removed) != 2 {
+		t.Errorf("Expected the two old backups to be pruned, got %+v, %v", removed, err)
+	}
+}
+
+func TestSaveVault_KeepsBackupsItCannotRekey(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	first := NewStorage(tempDir)
+	first.Initialize()
+	for i := 0; i < 3; i++ {
+		if err := first.SaveVault(vault, password); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+	}
+	if err := first.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	// A damaged backup cannot be rekeyed, so it is left as it is
+	backups, _ := first.ListBackups()
+	damagedName := backups[0].Name
+	damaged, _, _ := first.backend.Get(damagedName)
+	damaged[len(damaged)-1] ^= 0xff
+	first.backend.Put(damagedName, damaged)
+	if err := first.ChangeMasterPassword(vault, crypto.SecretFromString("changed password")); err != nil {
+		t.Fatalf("ChangeMasterPassword failed: %v", err)
+	}
+	if data, _, err := first.backend.Get(damagedName); err != nil || string(data) != string(damaged) {
+		t.Fatalf("The damaged backup was removed or changed: %v", err)
+	}
+	backups, _ = first.ListBackups()
+	contents := make(map[string]string)
+	for _, backup := range backups {
+		data, _, _ := first.backend.Get(backup.Name)
+		contents[backup.Name] = string(data)
+	}
+
+	// A new vault created in the same directory, as by pm init after
+	// user.dat was removed
+	os.Remove(filepath.Join(tempDir, UserFileName))
+	os.Remove(filepath.Join(tempDir, VaultFileName))
+	second := NewStorage(tempDir)
+	for i := 0; i < 3; i++ {
+		if err := second.SaveVault(vault, crypto.SecretFromString("other password")); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+	}
+	if err := second.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+	if err := second.ChangeMasterPassword(vault, crypto.SecretFromString("new password")); err != nil {
+		t.Fatalf("ChangeMasterPassword failed: %v", err)
+	}
+	if _, err := second.PruneBackups(BackupPolicy{Keep: 1}); err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+
+	// The first vault's backups are untouched, and its backup 1 does not
+	// take the place of the second vault's
+	for _, backup := range backups {
+		if data, _, err := second.backend.Get(backup.Name); err != nil || string(data) != contents[backup.Name] {
+			t.Errorf("Backup %d of the first vault was removed or changed: %v", backup.ID, err)
+		}
+	}
+	listed, _ := second.ListBackups()
+	others := 0
+	for _, backup := range listed {
+		if backup.OtherVault {
+			others++
+		}
+	}
+	if others != len(backups) || len(listed) != len(backups)+1 {
+		t.Errorf("Expected %d backups of the first vault and one of the second, got %+v", len(backups), listed)
+	}
+	if _, err := second.LoadBackup(1); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("Expected the first vault's backup 1 not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"passwordmanager/models"
+)
+
+// bindingKeyLabel separates the binding MAC key from other uses of the data key
+const bindingKeyLabel = "pm user.dat vault binding"
+
+// bindingMAC authenticates a vault ID and generation under a key derived
+// from the vault data key
+func bindingMAC(dataKey, vaultID []byte, generation uint64) []byte {
+	keyMAC := hmac.New(sha256.New, dataKey)
+	keyMAC.Write([]byte(bindingKeyLabel))
+
+	mac := hmac.New(sha256.New, keyMAC.Sum(nil))
+	mac.Write(vaultID)
+	mac.Write(binary.BigEndian.AppendUint64(nil, generation))
+	return mac.Sum(nil)
+}
+
+// bindUser records the unlocked vault's ID and generation in user
+func (s *Storage) bindUser(user *models.User) {
+	if s.dataKey == nil || s.vaultID == nil {
+		return
+	}
+	user.VaultBinding = &models.VaultBinding{
+		VaultID:    hex.EncodeToString(s.vaultID),
+		Generation: s.generation,
+		MAC:        hex.EncodeToString(bindingMAC(s.dataKey.Bytes(), s.vaultID, s.generation)),
+	}
+}
+
+// refreshBinding updates the binding in user.dat after the vault was saved
+func (s *Storage) refreshBinding() error {
+	user, err := s.LoadUser()
+	if err != nil || user == nil {
+		return err
+	}
+	return s.SaveUser(user)
+}
+
+// checkBinding verifies that the unlocked vault is the one user.dat was
+// bound to and is not older than the last one saved. Where user.dat has no
+// binding yet it is bound to this vault.
+func (s *Storage) checkBinding(header *VaultHeader) error {
+	user, err := s.LoadUser()
+	if err != nil || user == nil {
+		return err
+	}
+	if user.VaultBinding == nil {
+		return s.SaveUser(user)
+	}
+
+	return verifyBinding(s.dataKey.Bytes(), user.VaultBinding, header)
+}
+
+// verifyBinding checks a binding against the header of the vault dataKey
+// unlocked
+func verifyBinding(dataKey []byte, binding *models.VaultBinding, header *VaultHeader) error {
+	vaultID, err := hex.DecodeString(binding.VaultID)
+	if err != nil {
+		return fmt.Errorf("invalid vault binding: %w", err)
+	}
+	mac, err := hex.DecodeString(binding.MAC)
+	if err != nil {
+		return fmt.Errorf("invalid vault binding: %w", err)
+	}
+
+	if !hmac.Equal(mac, bindingMAC(dataKey, vaultID, binding.Generation)) ||
+		!hmac.Equal(vaultID, header.VaultID) {
+		return ErrVaultMismatch
+	}
+	if header.Generation < binding.Generation {
+		return ErrVaultRollback
+	}
+	return nil
+}
+
+// checkUnbound refuses a vault file in a format that predates bindings when
+// user.dat is already bound, which means an old copy was put in its place
+func (s *Storage) checkUnbound() error {
+	user, err := s.LoadUser()
+	if err != nil || user == nil {
+		return err
+	}
+	if user.VaultBinding != nil {
+		return ErrVaultRollback
+	}
+	return nil
+}
@@ -0,0 +1,627 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+)
+
+// DamagedVaultFileName is where a repair keeps the vault.dat it replaces
+const DamagedVaultFileName = VaultFileName + ".damaged"
+
+// clockSkew is how far in the future a timestamp may be before it is
+// reported
+const clockSkew = 24 * time.Hour
+
+// CheckStatus is the outcome of one check made by Diagnose
+type CheckStatus int
+
+const (
+	CheckOK CheckStatus = iota
+	CheckWarning
+	CheckFailed
+	CheckSkipped
+)
+
+func (c CheckStatus) String() string {
+	switch c {
+	case CheckOK:
+		return "ok"
+	case CheckWarning:
+		return "warning"
+	case CheckFailed:
+		return "failed"
+	default:
+		return "skipped"
+	}
+}
+
+// CheckResult is the outcome of one check. Where the problem can be fixed,
+// Repair describes the fix and ApplyRepair makes it.
+type CheckResult struct {
+	Check  string
+	Status CheckStatus
+	Detail string
+	Repair string
+
+	repair func() error
+}
+
+// ApplyRepair fixes the problem the check found
+func (r CheckResult) ApplyRepair() error {
+	if r.repair == nil {
+		return errors.New("no repair available")
+	}
+	return r.repair()
+}
+
+// diagnosis carries what the checks of one Diagnose learned so far
+type diagnosis struct {
+	s       *Storage
+	secret  *crypto.Secret
+	results []CheckResult
+
+	// user is user.dat, nil when it is missing or damaged
+	user *models.User
+
+	// header and ciphertext are those of vault.dat, when its header parses
+	header      *VaultHeader
+	headerBytes []byte
+	ciphertext  []byte
+
+	// plaintext is the decrypted vault until checkContents decodes it
+	plaintext []byte
+	vault     *models.PasswordVault
+
+	// restoreFor is the index of the result a backup restore would fix, or -1
+	restoreFor int
+}
+
+// Diagnose checks the files, header, keyslots, binding and contents of the
+// vault and reports every problem it finds, offering repairs where it can.
+// secret is the master password; without one only the files are checked.
+// Nothing is changed until a repair is applied. A failed check makes the
+// ones that depend on it report CheckSkipped.
+func (s *Storage) Diagnose(secret *crypto.Secret) []CheckResult {
+	if err := s.Lock(); err != nil {
+		return []CheckResult{{Check: "lock", Status: CheckFailed, Detail: err.Error()}}
+	}
+	defer s.Unlock()
+
+	d := &diagnosis{s: s, secret: secret, restoreFor: -1}
+	d.checkPermissions()
+	d.checkUser()
+	d.checkVaultFile()
+	d.checkTempFiles()
+	d.checkDecryption()
+	d.checkBinding()
+	d.checkContents()
+	d.checkEntries()
+	d.checkTimestamps()
+	d.checkBackups()
+	d.offerRestore()
+	return d.results
+}
+
+// report adds a result. The returned pointer is only valid until the next
+// report.
+func (d *diagnosis) report(check string, status CheckStatus, format string, args ...any) *CheckResult {
+	d.results = append(d.results, CheckResult{Check: check, Status: status, Detail: fmt.Sprintf(format, args...)})
+	return &d.results[len(d.results)-1]
+}
+
+// reportRestore adds a failed result that restoring a backup would fix
+func (d *diagnosis) reportRestore(check, format string, args ...any) {
+	d.report(check, CheckFailed, format, args...)
+	if d.restoreFor < 0 {
+		d.restoreFor = len(d.results) - 1
+	}
+}
+
+// checkPermissions looks for files in the data directory that other users
+// can access. Named vaults below it are checked on their own.
+func (d *diagnosis) checkPermissions() {
+	const check = "permissions"
+	if d.s.dataDir == "" || runtime.GOOS == "windows" {
+		d.report(check, CheckSkipped, "file permissions are not checked here")
+		return
+	}
+
+	var loose []string
+	err := filepath.WalkDir(d.s.dataDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if entry.IsDir() && entry.Name() == VaultsDirName && filepath.Dir(path) == d.s.dataDir {
+			return filepath.SkipDir
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			loose = append(loose, path)
+		}
+		return nil
+	})
+	if err != nil {
+		d.report(check, CheckFailed, "cannot read the data directory: %v", err)
+		return
+	}
+	if len(loose) == 0 {
+		d.report(check, CheckOK, "only the owner can access %s", d.s.dataDir)
+		return
+	}
+
+	r := d.report(check, CheckWarning, "%d files can be accessed by other users, such as %s", len(loose), loose[0])
+	r.Repair = "restrict them to the owner"
+	r.repair = func() error {
+		for _, path := range loose {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			mode := os.FileMode(0600)
+			if info.IsDir() {
+				mode = 0700
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// checkUser reads user.dat
+func (d *diagnosis) checkUser() {
+	const check = "user.dat"
+	user, err := d.s.LoadUser()
+	switch {
+	case err != nil:
+		d.report(check, CheckFailed, "cannot be read: %v", err)
+	case user == nil:
+		d.report(check, CheckFailed, "is missing")
+	case user.MasterPasswordHash != "":
+		d.report(check, CheckWarning, "holds a password verifier from an older version; it is removed on the next unlock")
+	case user.VaultBinding == nil:
+		d.report(check, CheckWarning, "is not bound to the vault yet; it is bound on the next unlock")
+	default:
+		d.report(check, CheckOK, "bound to vault %s", user.VaultBinding.VaultID)
+	}
+	d.user = user
+}
+
+// checkVaultFile reads vault.dat and its header, including the KDF
+// parameters and salt of every keyslot
+func (d *diagnosis) checkVaultFile() {
+	const check = "vault.dat"
+	data, _, err := d.s.backend.Get(VaultFileName)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			d.reportRestore(check, "is missing")
+		} else {
+			d.report(check, CheckFailed, "cannot be read: %v", err)
+		}
+		return
+	}
+
+	format := detectFormat(data)
+	if format < FormatBound {
+		if d.user != nil && d.user.VaultBinding != nil {
+			d.reportRestore(check, "is not a vault in the current format, but user.dat is bound to one; it is damaged or was replaced with an old copy")
+			return
+		}
+		d.report(check, CheckWarning, "uses format version %d, which is upgraded on the next unlock; open the vault once and run pm doctor again", format)
+		return
+	}
+
+	header, headerBytes, ciphertext, err := parseVaultHeader(data)
+	if err != nil {
+		d.reportRestore(check, "the header is damaged or the file is truncated (%d bytes): %v", len(data), err)
+		return
+	}
+	if len(header.Keyslots) == 0 {
+		d.reportRestore(check, "has no keyslots, so it cannot be unlocked")
+		return
+	}
+	for i, slot := range header.Keyslots {
+		if err := slot.KDF.Validate(); err != nil {
+			d.reportRestore(check, "keyslot %d (%s) has invalid KDF parameters: %v", i, slot.Type, err)
+			return
+		}
+	}
+
+	d.header, d.headerBytes, d.ciphertext = header, headerBytes, ciphertext
+	d.report(check, CheckOK, "format %d, %s, generation %d, %d keyslots", header.FormatVersion, header.CipherSuite, header.Generation, len(header.Keyslots))
+}
+
+// checkTempFiles looks for files left by an interrupted save
+func (d *diagnosis) checkTempFiles() {
+	const check = "interrupted saves"
+	temps, err := leftoverTempFiles(d.s.backend, VaultFileName)
+	switch {
+	case err != nil:
+		d.report(check, CheckFailed, "cannot list temporary files: %v", err)
+	case len(temps) > 0:
+		d.report(check, CheckWarning, "%d temporary files were left by an interrupted save; the next unlock completes the save or removes them", len(temps))
+	default:
+		d.report(check, CheckOK, "none")
+	}
+}
+
+// checkDecryption unlocks the vault and decrypts its contents, telling a
+// wrong master password apart from a damaged file
+func (d *diagnosis) checkDecryption() {
+	const check = "decryption"
+	if d.secret == nil {
+		d.report(check, CheckSkipped, "no master password given")
+		return
+	}
+	if d.header == nil {
+		d.report(check, CheckSkipped, "vault.dat could not be read")
+		return
+	}
+
+	if err := d.s.unlock(d.header, d.s.passwordSlotType(), d.secret); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidPassword):
+			d.report(check, CheckFailed, "the master password or key file opens none of the keyslots; this is a wrong password, not damage")
+		default:
+			d.report(check, CheckFailed, "%v", err)
+		}
+		return
+	}
+
+	plaintext, err := d.header.CipherSuite.Open(d.s.dataKey.Bytes(), d.ciphertext, d.header.additionalData(d.headerBytes))
+	if err != nil {
+		d.reportRestore(check, "the master password is correct, but the vault contents fail authentication: vault.dat is damaged")
+		return
+	}
+	d.plaintext = plaintext
+	d.report(check, CheckOK, "opened with the %s keyslot", d.s.keyslots[d.s.unlockedSlot].Type)
+}
+
+// checkBinding checks that vault.dat is the vault user.dat was bound to
+func (d *diagnosis) checkBinding() {
+	const check = "binding"
+	if d.s.dataKey == nil || d.header == nil {
+		d.report(check, CheckSkipped, "the vault is not unlocked")
+		return
+	}
+	if d.user == nil {
+		r := d.report(check, CheckFailed, "user.dat is missing or damaged")
+		r.Repair = "write a new user.dat bound to this vault"
+		r.repair = func() error {
+			now := time.Now()
+			return d.s.SaveUser(&models.User{CreatedAt: now, PasswordChangedAt: now})
+		}
+		return
+	}
+	if d.user.VaultBinding == nil {
+		d.report(check, CheckSkipped, "user.dat is not bound yet")
+		return
+	}
+
+	switch err := verifyBinding(d.s.dataKey.Bytes(), d.user.VaultBinding, d.header); {
+	case errors.Is(err, ErrVaultMismatch):
+		d.report(check, CheckFailed, "vault.dat belongs to a different install than user.dat; put back the vault.dat of this install")
+	case errors.Is(err, ErrVaultRollback):
+		d.reportRestore(check, "vault.dat is generation %d, but generation %d was saved since; it was replaced with an older copy", d.header.Generation, d.user.VaultBinding.Generation)
+	case err != nil:
+		d.report(check, CheckFailed, "%v", err)
+	default:
+		d.report(check, CheckOK, "vault.dat matches user.dat")
+	}
+}
+
+// checkContents decodes the decrypted vault
+func (d *diagnosis) checkContents() {
+	const check = "contents"
+	if d.plaintext == nil {
+		d.report(check, CheckSkipped, "the vault is not decrypted")
+		return
+	}
+
+	vault, applied, err := decodeVault(d.plaintext)
+	d.plaintext = nil
+	switch {
+	case errors.Is(err, ErrVaultTooNew):
+		d.report(check, CheckFailed, "%v; upgrade pm", err)
+		return
+	case err != nil:
+		d.reportRestore(check, "the decrypted vault is not valid: %v", err)
+		return
+	}
+
+	d.vault = vault
+	if len(applied) > 0 {
+		d.report(check, CheckWarning, "version %s, %d entries; run 'pm vault migrate' to save it in version %s", applied[0].From, len(vault.Entries), CurrentVaultVersion)
+		return
+	}
+	d.report(check, CheckOK, "version %s, %d entries", vault.Version, len(vault.Entries))
+}
+
+// checkEntries looks for entries without a unique ID or title
+func (d *diagnosis) checkEntries() {
+	const check = "entries"
+	if d.vault == nil {
+		d.report(check, CheckSkipped, "the vault is not decrypted")
+		return
+	}
+
+	ids := make(map[string]int)
+	paths := make(map[string]int)
+	badIDs, untitled := 0, 0
+	var sharedPaths []string
+	for _, entry := range d.vault.Entries {
+		if entry.ID == "" || ids[entry.ID] > 0 {
+			badIDs++
+		}
+		ids[entry.ID]++
+
+		if entry.Title == "" {
+			untitled++
+			continue
+		}
+		path := EntryPath(entry)
+		paths[path]++
+		if paths[path] == 2 {
+			sharedPaths = append(sharedPaths, strconv.Quote(path))
+		}
+	}
+
+	problems := false
+	if badIDs > 0 {
+		problems = true
+		r := d.report(check, CheckFailed, "%d entries have no ID or share it with another entry", badIDs)
+		r.Repair = "give those entries new IDs"
+		r.repair = func() error {
+			if err := assignMissingIDs(d.vault); err != nil {
+				return err
+			}
+			return d.s.SaveVault(d.vault, nil)
+		}
+	}
+	if len(sharedPaths) > 0 {
+		problems = true
+		d.report(check, CheckWarning, "more than one entry is named %s; address them by the IDs pm list shows", strings.Join(sharedPaths, ", "))
+	}
+	if untitled > 0 {
+		problems = true
+		d.report(check, CheckWarning, "%d entries have no title", untitled)
+	}
+	if !problems {
+		d.report(check, CheckOK, "IDs and titles are unique")
+	}
+}
+
+// assignMissingIDs gives every entry without an ID, or with the ID of an
+// earlier entry, a new one
+func assignMissingIDs(vault *models.PasswordVault) error {
+	seen := make(map[string]bool)
+	for i := range vault.Entries {
+		entry := &vault.Entries[i]
+		if entry.ID != "" && !seen[entry.ID] {
+			seen[entry.ID] = true
+			continue
+		}
+		id, err := NewEntryID()
+		if err != nil {
+			return err
+		}
+		entry.ID = id
+		seen[id] = true
+	}
+	return nil
+}
+
+// checkTimestamps looks for missing, future and out of order timestamps
+func (d *diagnosis) checkTimestamps() {
+	const check = "timestamps"
+	if d.vault == nil {
+		d.report(check, CheckSkipped, "the vault is not decrypted")
+		return
+	}
+
+	now := time.Now()
+	var bad []string
+	for _, entry := range d.vault.Entries {
+		if !saneTimestamps(entry, now) {
+			bad = append(bad, strconv.Quote(entry.Title))
+		}
+	}
+	if len(bad) == 0 {
+		d.report(check, CheckOK, "all entries are in order")
+		return
+	}
+
+	r := d.report(check, CheckWarning, "%d entries have missing, future or out of order timestamps, such as %s", len(bad), bad[0])
+	r.Repair = "reset them to the nearest valid time"
+	r.repair = func() error {
+		fixTimestamps(d.vault, time.Now())
+		return d.s.SaveVault(d.vault, nil)
+	}
+}
+
+// saneTimestamps reports whether an entry was created and updated at known
+// times, in that order and not in the future
+func saneTimestamps(entry models.PasswordEntry, now time.Time) bool {
+	return !entry.CreatedAt.IsZero() && !entry.UpdatedAt.IsZero() &&
+		!entry.UpdatedAt.After(now.Add(clockSkew)) && !entry.CreatedAt.After(entry.UpdatedAt)
+}
+
+// fixTimestamps moves future or missing update times to now and creation
+// times to no later than the update
+func fixTimestamps(vault *models.PasswordVault, now time.Time) {
+	for i := range vault.Entries {
+		entry := &vault.Entries[i]
+		if saneTimestamps(*entry, now) {
+			continue
+		}
+		if entry.UpdatedAt.IsZero() || entry.UpdatedAt.After(now.Add(clockSkew)) {
+			entry.UpdatedAt = now
+		}
+		if entry.CreatedAt.IsZero() || entry.CreatedAt.After(entry.UpdatedAt) {
+			entry.CreatedAt = entry.UpdatedAt
+		}
+	}
+}
+
+// checkBackups checks that every backup of this vault can be decrypted with
+// the data key. Backups of other vaults are only counted.
+func (d *diagnosis) checkBackups() {
+	const check = "backups"
+	backups, err := d.s.ListBackups()
+	if err != nil {
+		d.report(check, CheckFailed, "%v", err)
+		return
+	}
+	if len(backups) == 0 {
+		d.report(check, CheckOK, "none yet")
+		return
+	}
+	if d.s.dataKey == nil {
+		d.report(check, CheckSkipped, "%d backups, not checked without unlocking the vault", len(backups))
+		return
+	}
+
+	var unreadable []string
+	others := 0
+	for _, backup := range backups {
+		if backup.OtherVault {
+			others++
+			continue
+		}
+		if _, err := d.s.LoadBackup(backup.ID); err != nil {
+			unreadable = append(unreadable, strconv.FormatUint(backup.ID, 10))
+		}
+	}
+	own := len(backups) - others
+	if len(unreadable) > 0 {
+		d.report(check, CheckWarning, "%d of %d backups cannot be read: %s", len(unreadable), own, strings.Join(unreadable, ", "))
+		return
+	}
+	if others > 0 {
+		d.report(check, CheckOK, "%d backups, all readable; %d more belong to another vault and are left alone", own, others)
+		return
+	}
+	d.report(check, CheckOK, "%d backups, all readable", own)
+}
+
+// offerRestore attaches a restore of the newest readable backup to the
+// first failure it would fix
+func (d *diagnosis) offerRestore() {
+	if d.restoreFor < 0 {
+		return
+	}
+	result := &d.results[d.restoreFor]
+	if d.secret == nil {
+		result.Detail += "; run pm doctor with the master password to look for a backup"
+		return
+	}
+
+	backups, err := d.s.ListBackups()
+	if err != nil {
+		return
+	}
+	for _, backup := range backups {
+		header, vault, err := d.openBackup(backup)
+		if err != nil {
+			continue
+		}
+		result.Repair = fmt.Sprintf("restore backup %d from %s (%d entries); the current vault.dat is kept as %s",
+			backup.ID, backup.CreatedAt.Format("2006-01-02 15:04:05"), len(vault.Entries), DamagedVaultFileName)
+		result.repair = func() error {
+			return d.restore(header, vault)
+		}
+		return
+	}
+	result.Detail += "; no readable backup was found"
+}
+
+// openBackup decrypts a backup with the data key of the unlocked vault, or
+// else with the secret, and returns its header and contents
+func (d *diagnosis) openBackup(backup Backup) (*VaultHeader, *models.PasswordVault, error) {
+	data, _, err := d.s.backend.Get(backup.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, headerBytes, ciphertext, err := parseVaultHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.FormatVersion < FormatBound {
+		return nil, nil, fmt.Errorf("backup %d predates vault IDs", backup.ID)
+	}
+
+	var dataKey []byte
+	if d.s.dataKey != nil && bytes.Equal(header.VaultID, d.s.vaultID) {
+		dataKey = append([]byte(nil), d.s.dataKey.Bytes()...)
+	} else if dataKey, _, err = unlockKeyslots(header.Keyslots, d.s.passwordSlotType(), d.secret, d.s.keyFile); err != nil {
+		return nil, nil, err
+	}
+	defer crypto.Wipe(dataKey)
+
+	plaintext, err := header.CipherSuite.Open(dataKey, ciphertext, header.additionalData(headerBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	vault, _, err := decodeVault(plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, vault, nil
+}
+
+// restore replaces vault.dat with the contents of a backup. Where the
+// current vault.dat could not be unlocked, the keyslots of the backup are
+// used. The generation carries on past any the vault or user.dat has seen.
+func (d *diagnosis) restore(header *VaultHeader, vault *models.PasswordVault) error {
+	if err := d.s.Lock(); err != nil {
+		return err
+	}
+	defer d.s.Unlock()
+
+	if d.s.dataKey == nil || !bytes.Equal(header.VaultID, d.s.vaultID) {
+		if err := d.s.unlock(header, d.s.passwordSlotType(), d.secret); err != nil {
+			return err
+		}
+	}
+	generation := d.s.generation
+	if d.header != nil && d.header.Generation > generation {
+		generation = d.header.Generation
+	}
+	if d.user != nil && d.user.VaultBinding != nil && d.user.VaultBinding.Generation > generation {
+		generation = d.user.VaultBinding.Generation
+	}
+
+	// SaveVault would back up the damaged file as if it were a vault
+	data, _, err := d.s.backend.Get(VaultFileName)
+	if err == nil {
+		if _, err := d.s.backend.Put(DamagedVaultFileName, data); err != nil {
+			return fmt.Errorf("failed to keep damaged vault: %w", err)
+		}
+		if err := d.s.backend.Delete(VaultFileName); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, ErrBlobNotFound) {
+		return err
+	}
+
+	d.s.generation = generation
+	return d.s.SaveVault(vault, nil)
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupDoctorVault saves a vault twice, so that a backup exists, and binds
+// user.dat to it
+func setupDoctorVault(t *testing.T, tempDir string, entries []models.PasswordEntry) *Storage {
+	store := NewStorage(tempDir)
+	store.Initialize()
+	password := crypto.SecretFromString("password")
+
+	vault := &models.PasswordVault{Entries: entries}
+	for i := 0; i < 2; i++ {
+		if err := store.SaveVault(vault, password); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+	}
+	if err := store.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+	return NewStorage(tempDir)
+}
+
+// findCheck returns the first result of the named check
+func findCheck(t *testing.T, results []CheckResult, check string) CheckResult {
+	for _, result := range results {
+		if result.Check == check {
+			return result
+		}
+	}
+	t.Fatalf("No %s check in %+v", check, results)
+	return CheckResult{}
+}
+
+func TestDiagnose_Healthy(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	now := time.Now()
+	store := setupDoctorVault(t, tempDir, []models.PasswordEntry{
+		{ID: "1", Title: "Mail", CreatedAt: now, UpdatedAt: now},
+	})
+
+	for _, result := range store.Diagnose(crypto.SecretFromString("password")) {
+		if result.Status != CheckOK {
+			t.Errorf("%s: %s: %s", result.Check, result.Status, result.Detail)
+		}
+	}
+}
+
+func TestDiagnose_WrongPassword(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := setupDoctorVault(t, tempDir, nil)
+
+	results := store.Diagnose(crypto.SecretFromString("wrong"))
+	result := findCheck(t, results, "decryption")
+	if result.Status != CheckFailed || !strings.Contains(result.Detail, "wrong password") {
+		t.Errorf("Expected a wrong password, got %+v", result)
+	}
+	if result.Repair != "" {
+		t.Errorf("A wrong password should not offer a repair, got %q", result.Repair)
+	}
+	if findCheck(t, results, "vault.dat").Status != CheckOK {
+		t.Error("The vault file itself is fine")
+	}
+}
+
+func TestDiagnose_RestoreDamagedVault(t *testing.T) {
+	tests := map[string]func(data []byte) []byte{
+		"corrupted": func(data []byte) []byte {
+			data[len(data)-1] ^= 0xff
+			return data
+		},
+		"truncated": func(data []byte) []byte {
+			return data[:20]
+		},
+	}
+
+	for name, damage := range tests {
+		t.Run(name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			password := crypto.SecretFromString("password")
+			store := setupDoctorVault(t, tempDir, []models.PasswordEntry{{ID: "1", Title: "Mail"}})
+
+			path := filepath.Join(tempDir, VaultFileName)
+			data, _ := os.ReadFile(path)
+			os.WriteFile(path, damage(data), 0600)
+
+			var damaged CheckResult
+			for _, result := range store.Diagnose(password) {
+				if result.Status == CheckFailed {
+					damaged = result
+					break
+				}
+			}
+			if damaged.Repair == "" {
+				t.Fatalf("Expected a restore to be offered, got %+v", damaged)
+			}
+			if err := damaged.ApplyRepair(); err != nil {
+				t.Fatalf("ApplyRepair failed: %v", err)
+			}
+
+			vault, err := NewStorage(tempDir).LoadVault(password)
+			if err != nil {
+				t.Fatalf("LoadVault after restore failed: %v", err)
+			}
+			if len(vault.Entries) != 1 || vault.Entries[0].Title != "Mail" {
+				t.Errorf("Unexpected restored vault: %+v", vault.Entries)
+			}
+			if _, err := os.Stat(filepath.Join(tempDir, DamagedVaultFileName)); err != nil {
+				t.Errorf("The damaged vault should be kept: %v", err)
+			}
+		})
+	}
+}
+
+func TestDiagnose_RepairEntries(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	now := time.Now()
+	store := setupDoctorVault(t, tempDir, []models.PasswordEntry{
+		{ID: "1", Title: "Mail", CreatedAt: now, UpdatedAt: now},
+		{ID: "1", Title: "Bank", CreatedAt: now.Add(48 * time.Hour), UpdatedAt: now},
+		{Title: "Mail"},
+	})
+
+	results := store.Diagnose(password)
+	ids := findCheck(t, results, "entries")
+	if ids.Status != CheckFailed || ids.Repair == "" {
+		t.Fatalf("Expected duplicate IDs, got %+v", ids)
+	}
+	sharedTitle := false
+	for _, result := range results {
+		if result.Check == "entries" && result.Status == CheckWarning && strings.Contains(result.Detail, `"Mail"`) {
+			sharedTitle = true
+		}
+	}
+	if !sharedTitle {
+		t.Errorf("Expected the shared title to be reported, got %+v", results)
+	}
+	timestamps := findCheck(t, results, "timestamps")
+	if timestamps.Status != CheckWarning || timestamps.Repair == "" {
+		t.Fatalf("Expected bad timestamps, got %+v", timestamps)
+	}
+
+	if err := ids.ApplyRepair(); err != nil {
+		t.Fatalf("Repairing IDs failed: %v", err)
+	}
+	if err := timestamps.ApplyRepair(); err != nil {
+		t.Fatalf("Repairing timestamps failed: %v", err)
+	}
+
+	vault, err := NewStorage(tempDir).LoadVault(password)
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, entry := range vault.Entries {
+		if entry.ID == "" || seen[entry.ID] {
+			t.Errorf("Entry %q still has ID %q", entry.Title, entry.ID)
+		}
+		seen[entry.ID] = true
+		if !saneTimestamps(entry, time.Now()) {
+			t.Errorf("Entry %q still has bad timestamps", entry.Title)
+		}
+	}
+	if vault.Entries[0].ID != "1" {
+		t.Errorf("The first entry should keep its ID, got %q", vault.Entries[0].ID)
+	}
+}
+
+func TestDiagnose_Permissions(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := setupDoctorVault(t, tempDir, nil)
+	os.Chmod(filepath.Join(tempDir, UserFileName), 0644)
+
+	result := findCheck(t, store.Diagnose(nil), "permissions")
+	if result.Status != CheckWarning {
+		t.Fatalf("Expected a permissions warning, got %+v", result)
+	}
+	if err := result.ApplyRepair(); err != nil {
+		t.Fatalf("ApplyRepair failed: %v", err)
+	}
+	info, _ := os.Stat(filepath.Join(tempDir, UserFileName))
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected 0600, got %o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"passwordmanager/models"
+)
+
+// MinIDPrefix is the shortest ID prefix FindEntry accepts
+const MinIDPrefix = 4
+
+// ShortIDLength is the length of the IDs pm list shows
+const ShortIDLength = 8
+
+var (
+	// ErrEntryNotFound is returned by FindEntry when nothing matches
+	ErrEntryNotFound = errors.New("entry not found")
+
+	// ErrAmbiguousEntry is returned by FindEntry when several entries match
+	ErrAmbiguousEntry = errors.New("more than one entry matches")
+)
+
+// AmbiguousEntryError is returned by FindEntry when Ref matches more than
+// one entry. Matches are their indexes in vault.Entries.
+type AmbiguousEntryError struct {
+	Ref     string
+	Matches []int
+}
+
+func (e *AmbiguousEntryError) Error() string {
+	return fmt.Sprintf("%v %q", ErrAmbiguousEntry, e.Ref)
+}
+
+func (e *AmbiguousEntryError) Unwrap() error {
+	return ErrAmbiguousEntry
+}
+
+// NewEntryID returns a random version 4 UUID for a new entry
+func NewEntryID() (string, error) {
+	var id [16]byte
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return "", fmt.Errorf("failed to generate entry ID: %w", err)
+	}
+	return formatUUID(id, 4), nil
+}
+
+// derivedEntryID returns a UUID derived from an entry ID of an earlier
+// version, so that every process upgrading the same vault agrees on it
+func derivedEntryID(legacy string) string {
+	sum := sha256.Sum256([]byte("pm entry id\x00" + legacy))
+	var id [16]byte
+	copy(id[:], sum[:])
+	return formatUUID(id, 8)
+}
+
+// formatUUID sets the version and variant bits of id and formats it
+func formatUUID(id [16]byte, version byte) string {
+	id[6] = id[6]&0x0f | version<<4
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+// IsEntryID reports whether id is a UUID in the form NewEntryID returns
+func IsEntryID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, c := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdef", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ShortID returns the first ShortIDLength characters of id
+func ShortID(id string) string {
+	if len(id) > ShortIDLength {
+		return id[:ShortIDLength]
+	}
+	return id
+}
+
+// CleanFolder returns folder with surrounding spaces and empty path
+// elements removed, so " work//dev/ " becomes "work/dev"
+func CleanFolder(folder string) string {
+	var parts []string
+	for _, part := range strings.Split(folder, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// CleanTags returns tags with surrounding spaces removed, leaving out empty
+// tags and tags that repeat an earlier one, ignoring case
+func CleanTags(tags []string) []string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned
+}
+
+// EntryPath returns the folder and title of entry joined by a slash, or
+// just the title for an entry at the top level
+func EntryPath(entry models.PasswordEntry) string {
+	if entry.Folder == "" {
+		return entry.Title
+	}
+	return entry.Folder + "/" + entry.Title
+}
+
+// FindEntry returns the index in vault.Entries of the entry ref names: the
+// entry with that ID, else the entry with that title or folder/title path,
+// else the entry whose ID starts with ref if ref has at least MinIDPrefix
+// characters. It fails with ErrEntryNotFound, or with an
+// *AmbiguousEntryError if several entries have the title, path or prefix.
+func FindEntry(vault *models.PasswordVault, ref string) (int, error) {
+	return findIn(vault.Entries, ref)
+}
+
+// findIn returns the index in entries of the entry ref names, as FindEntry
+// describes
+func findIn(entries []models.PasswordEntry, ref string) (int, error) {
+	for i, entry := range entries {
+		if entry.ID == ref {
+			return i, nil
+		}
+	}
+
+	matches := matchEntries(entries, func(entry models.PasswordEntry) bool {
+		return entry.Title == ref || EntryPath(entry) == ref
+	})
+	if len(matches) == 0 && len(ref) >= MinIDPrefix {
+		prefix := strings.ToLower(ref)
+		matches = matchEntries(entries, func(entry models.PasswordEntry) bool {
+			return strings.HasPrefix(entry.ID, prefix)
+		})
+	}
+
+	switch len(matches) {
+	case 0:
+		return -1, ErrEntryNotFound
+	case 1:
+		return matches[0], nil
+	default:
+		return -1, &AmbiguousEntryError{Ref: ref, Matches: matches}
+	}
+}
+
+// matchEntries returns the indexes of the entries match accepts
+func matchEntries(entries []models.PasswordEntry, match func(models.PasswordEntry) bool) []int {
+	var matches []int
+	for i, entry := range entries {
+		if match(entry) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// TitleInUse reports whether an entry in folder has the given title.
+// Entries in different folders may share a title.
+func TitleInUse(vault *models.PasswordVault, folder, title string) bool {
+	for _, entry := range vault.Entries {
+		if entry.Folder == folder && entry.Title == title {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateEntryIDs replaces the IDs of entries and trashed entries that are
+// not UUIDs, and IDs shared with an earlier entry, in the decoded vault JSON
+func migrateEntryIDs(vault map[string]any) error {
+	seen := make(map[string]bool)
+	for _, field := range []string{"entries", "trash"} {
+		list, _ := vault[field].([]any)
+		for _, item := range list {
+			entry, ok := item.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s holds %T, not an entry", field, item)
+			}
+			id, _ := entry["id"].(string)
+			if id != "" && !IsEntryID(id) {
+				id = derivedEntryID(id)
+			}
+			if id == "" || seen[id] {
+				var err error
+				if id, err = NewEntryID(); err != nil {
+					return err
+				}
+			}
+			seen[id] = true
+			entry["id"] = id
+		}
+	}
+	return nil
+}
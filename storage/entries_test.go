@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"errors"
+	"passwordmanager/models"
+	"reflect"
+	"testing"
+)
+
+func TestNewEntryID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := NewEntryID()
+		if err != nil {
+			t.Fatalf("NewEntryID failed: %v", err)
+		}
+		if !IsEntryID(id) || id[14] != '4' {
+			t.Fatalf("%q is not a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("NewEntryID returned %s twice", id)
+		}
+		seen[id] = true
+	}
+
+	for _, id := range []string{"", "1700000000000000000", "0F4E2D3C-1B2A-4987-8654-3210FEDCBA98"} {
+		if IsEntryID(id) {
+			t.Errorf("%q should not be an entry ID", id)
+		}
+	}
+}
+
+func TestFindEntry(t *testing.T) {
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{
+		{ID: "0f4e2d3c-1b2a-4987-8654-3210fedcba98", Title: "GitHub"},
+		{ID: "0f4e9999-1b2a-4987-8654-3210fedcba98", Title: "GitHub"},
+		{ID: "7c1d2e3f-4a5b-4c6d-8e7f-8091a2b3c4d5", Title: "Mail"},
+		{ID: "a1b2c3d4-0000-4000-8000-000000000000", Title: "7c1d"},
+	}}
+
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"0f4e9999-1b2a-4987-8654-3210fedcba98", 1},
+		{"Mail", 2},
+		{"0F4E2D", 0},
+		// A title wins over an ID prefix
+		{"7c1d", 3},
+	}
+	for _, tt := range tests {
+		index, err := FindEntry(vault, tt.ref)
+		if err != nil || index != tt.want {
+			t.Errorf("FindEntry(%q) = %d, %v; expected %d", tt.ref, index, err, tt.want)
+		}
+	}
+
+	var ambiguous *AmbiguousEntryError
+	if _, err := FindEntry(vault, "GitHub"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 2 {
+		t.Errorf("Expected both GitHub entries to match, got %v", err)
+	}
+	if _, err := FindEntry(vault, "0f4e"); !errors.Is(err, ErrAmbiguousEntry) {
+		t.Errorf("Expected a shared prefix to be ambiguous, got %v", err)
+	}
+	// Prefixes shorter than MinIDPrefix are not matched
+	if _, err := FindEntry(vault, "7c1"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("Expected ErrEntryNotFound, got %v", err)
+	}
+}
+
+func TestFindEntry_Paths(t *testing.T) {
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{
+		{ID: "0f4e2d3c-1b2a-4987-8654-3210fedcba98", Title: "GitHub", Folder: "work"},
+		{ID: "7c1d2e3f-4a5b-4c6d-8e7f-8091a2b3c4d5", Title: "GitHub", Folder: "work/dev"},
+		{ID: "a1b2c3d4-0000-4000-8000-000000000000", Title: "GitHub"},
+		{ID: "b1b2c3d4-0000-4000-8000-000000000000", Title: "Mail", Folder: "home"},
+		{ID: "c1b2c3d4-0000-4000-8000-000000000000", Title: "Mail", Folder: "home"},
+	}}
+
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"work/GitHub", 0},
+		{"work/dev/GitHub", 1},
+	}
+	for _, tt := range tests {
+		index, err := FindEntry(vault, tt.ref)
+		if err != nil || index != tt.want {
+			t.Errorf("FindEntry(%q) = %d, %v; expected %d", tt.ref, index, err, tt.want)
+		}
+	}
+
+	var ambiguous *AmbiguousEntryError
+	// The title alone matches the entries in every folder
+	if _, err := FindEntry(vault, "GitHub"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 3 {
+		t.Errorf("Expected the three GitHub entries to match, got %v", err)
+	}
+	if _, err := FindEntry(vault, "home/Mail"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 2 {
+		t.Errorf("Expected both home/Mail entries to match, got %v", err)
+	}
+	// Paths must match in full
+	for _, ref := range []string{"dev/GitHub", "home/GitHub"} {
+		if _, err := FindEntry(vault, ref); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("FindEntry(%q): expected ErrEntryNotFound, got %v", ref, err)
+		}
+	}
+
+	// A title containing a slash can give an entry the same path as one in
+	// a nested folder
+	vault.Entries = append(vault.Entries, models.PasswordEntry{ID: "d1b2c3d4-0000-4000-8000-000000000000", Title: "dev/GitHub", Folder: "work"})
+	if _, err := FindEntry(vault, "work/dev/GitHub"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 2 {
+		t.Errorf("Expected work/dev/GitHub to be ambiguous, got %v", err)
+	}
+}
+
+func TestCleanFolder(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"/":             "",
+		"work":          "work",
+		" work//dev/ ":  "work/dev",
+		"/home/ bank /": "home/bank",
+	}
+	for folder, want := range tests {
+		if got := CleanFolder(folder); got != want {
+			t.Errorf("CleanFolder(%q) = %q; expected %q", folder, got, want)
+		}
+	}
+}
+
+func TestCleanTags(t *testing.T) {
+	got := CleanTags([]string{" work", "", "2FA", "Work", "2fa ", "dev"})
+	if want := []string{"work", "2FA", "dev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := CleanTags([]string{""}); got != nil {
+		t.Errorf("Expected no tags, got %q", got)
+	}
+}
+
+func TestTitleInUse(t *testing.T) {
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{
+		{Title: "GitHub", Folder: "work"},
+	}}
+	if !TitleInUse(vault, "work", "GitHub") {
+		t.Error("Expected work/GitHub to be in use")
+	}
+	if TitleInUse(vault, "", "GitHub") || TitleInUse(vault, "home", "GitHub") {
+		t.Error("A title should only be in use within its folder")
+	}
+}
+
+func TestMigrateEntryIDs(t *testing.T) {
+	newVault := func() map[string]any {
+		return map[string]any{
+			"entries": []any{
+				map[string]any{"id": "1700000000000000000", "title": "Mail"},
+				map[string]any{"id": "1700000000000000000", "title": "Copy"},
+				map[string]any{"id": "0f4e2d3c-1b2a-4987-8654-3210fedcba98", "title": "Bank"},
+			},
+			"trash": []any{
+				map[string]any{"id": "", "title": "Old"},
+			},
+		}
+	}
+	id := func(vault map[string]any, field string, i int) string {
+		return vault[field].([]any)[i].(map[string]any)["id"].(string)
+	}
+
+	first, second := newVault(), newVault()
+	for _, vault := range []map[string]any{first, second} {
+		if err := migrateEntryIDs(vault); err != nil {
+			t.Fatalf("migrateEntryIDs failed: %v", err)
+		}
+	}
+
+	if id(first, "entries", 0) != id(second, "entries", 0) {
+		t.Error("Migrating the same vault twice should give the same IDs")
+	}
+	if id(first, "entries", 2) != "0f4e2d3c-1b2a-4987-8654-3210fedcba98" {
+		t.Errorf("A UUID should be kept, got %s", id(first, "entries", 2))
+	}
+	seen := make(map[string]bool)
+	for _, got := range []string{id(first, "entries", 0), id(first, "entries", 1), id(first, "entries", 2), id(first, "trash", 0)} {
+		if !IsEntryID(got) {
+			t.Errorf("%q is not an entry ID", got)
+		}
+		if seen[got] {
+			t.Errorf("ID %s is used twice", got)
+		}
+		seen[got] = true
+	}
+}
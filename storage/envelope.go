@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"passwordmanager/crypto"
+)
+
+// vaultMagic identifies a vault file written with the self-describing envelope
+var vaultMagic = []byte("PMVAULT\x00")
+
+// Vault file format versions. Versions below FormatEnvelope predate the
+// envelope header and are recognized by sniffing the file contents.
+const (
+	// FormatLegacy is nonce || ciphertext under a single SHA-256 of the password
+	FormatLegacy uint16 = 0
+	// FormatKDF is a crypto.EncryptData blob with inline KDF parameters
+	FormatKDF uint16 = 1
+	// FormatEnvelope encrypts the vault directly under the password-derived key
+	FormatEnvelope uint16 = 2
+	// FormatKeyslots encrypts the vault under a random data key that is
+	// wrapped by one or more keyslots
+	FormatKeyslots uint16 = 3
+	// FormatBound adds a vault ID and a generation counter to FormatKeyslots
+	// and binds the vault contents to them
+	FormatBound uint16 = 4
+
+	// CurrentFormatVersion is the format every vault is written in
+	CurrentFormatVersion = FormatBound
+)
+
+// VaultIDSize is the size in bytes of the random ID given to every vault
+const VaultIDSize = 16
+
+// roleVault is the file role vault.dat contents are encrypted under
+const roleVault = "vault"
+
+// VaultHeader describes how a vault file was encrypted. It is stored in the
+// clear at the start of vault.dat and authenticated as GCM additional data.
+//
+// On disk it is laid out as
+//
+//	magic(8) | formatVersion(2) | bodyLen(4) | body
+//
+// where body for FormatEnvelope is
+//
+//	cipherSuite(1) | kdfParams
+//
+// for FormatKeyslots is
+//
+//	cipherSuite(1) | slotCount(1) | keyslot...
+//
+// and for FormatBound is
+//
+//	cipherSuite(1) | vaultID(16) | generation(8) | slotCount(1) | keyslot...
+type VaultHeader struct {
+	FormatVersion uint16
+	CipherSuite   crypto.CipherSuite
+
+	// VaultID and Generation are only used by FormatBound. Generation is
+	// incremented on every save.
+	VaultID    []byte
+	Generation uint64
+
+	// KDF is only used by FormatEnvelope and FormatKDF
+	KDF crypto.KDFParams
+
+	// Keyslots is only used by FormatKeyslots
+	Keyslots []Keyslot
+}
+
+// MarshalBinary encodes the header in its on-disk form
+func (h *VaultHeader) MarshalBinary() ([]byte, error) {
+	body := []byte{byte(h.CipherSuite)}
+
+	switch h.FormatVersion {
+	case FormatEnvelope:
+		kdf, err := h.KDF.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, kdf...)
+	case FormatKeyslots, FormatBound:
+		if h.FormatVersion == FormatBound {
+			if len(h.VaultID) != VaultIDSize {
+				return nil, fmt.Errorf("invalid vault ID length: %d", len(h.VaultID))
+			}
+			body = append(body, h.VaultID...)
+			body = binary.BigEndian.AppendUint64(body, h.Generation)
+		}
+		if len(h.Keyslots) == 0 || len(h.Keyslots) > 255 {
+			return nil, fmt.Errorf("invalid keyslot count: %d", len(h.Keyslots))
+		}
+		body = append(body, byte(len(h.Keyslots)))
+		for _, slot := range h.Keyslots {
+			encoded, err := slot.marshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, encoded...)
+		}
+	default:
+		return nil, fmt.Errorf("cannot write vault format version %d", h.FormatVersion)
+	}
+
+	buf := make([]byte, 0, len(vaultMagic)+6+len(body))
+	buf = append(buf, vaultMagic...)
+	buf = binary.BigEndian.AppendUint16(buf, h.FormatVersion)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(body)))
+	return append(buf, body...), nil
+}
+
+// parseVaultHeader splits an enveloped vault file into its header, the raw
+// header bytes used as additional data, and the remaining ciphertext
+func parseVaultHeader(data []byte) (*VaultHeader, []byte, []byte, error) {
+	if !bytes.HasPrefix(data, vaultMagic) {
+		return nil, nil, nil, fmt.Errorf("missing vault header")
+	}
+
+	rest := data[len(vaultMagic):]
+	if len(rest) < 6 {
+		return nil, nil, nil, fmt.Errorf("vault header truncated")
+	}
+	version := binary.BigEndian.Uint16(rest[0:2])
+	bodyLen := int(binary.BigEndian.Uint32(rest[2:6]))
+	if len(rest)-6 < bodyLen {
+		return nil, nil, nil, fmt.Errorf("vault header truncated")
+	}
+
+	if version > CurrentFormatVersion {
+		return nil, nil, nil, fmt.Errorf("vault format version %d is newer than this version of pm supports (%d)", version, CurrentFormatVersion)
+	}
+	if version < FormatEnvelope {
+		return nil, nil, nil, fmt.Errorf("invalid vault format version %d in header", version)
+	}
+
+	body := rest[6 : 6+bodyLen]
+	if len(body) < 1 {
+		return nil, nil, nil, fmt.Errorf("vault header truncated")
+	}
+	header := &VaultHeader{
+		FormatVersion: version,
+		CipherSuite:   crypto.CipherSuite(body[0]),
+	}
+	if !header.CipherSuite.Valid() {
+		return nil, nil, nil, fmt.Errorf("unsupported cipher suite: %s", header.CipherSuite)
+	}
+
+	switch version {
+	case FormatEnvelope:
+		kdf, _, err := crypto.UnmarshalKDFParams(body[1:])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		header.KDF = kdf
+	case FormatKeyslots, FormatBound:
+		rest := body[1:]
+		if version == FormatBound {
+			if len(rest) < VaultIDSize+8 {
+				return nil, nil, nil, fmt.Errorf("vault header truncated")
+			}
+			header.VaultID = append([]byte(nil), rest[:VaultIDSize]...)
+			header.Generation = binary.BigEndian.Uint64(rest[VaultIDSize:])
+			rest = rest[VaultIDSize+8:]
+		}
+		if len(rest) < 1 {
+			return nil, nil, nil, fmt.Errorf("vault header truncated")
+		}
+		count := int(rest[0])
+		slots := rest[1:]
+		for i := 0; i < count; i++ {
+			slot, n, err := unmarshalKeyslot(slots)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("keyslot %d: %w", i, err)
+			}
+			header.Keyslots = append(header.Keyslots, slot)
+			slots = slots[n:]
+		}
+	}
+
+	headerLen := len(vaultMagic) + 6 + bodyLen
+	return header, data[:headerLen], data[headerLen:], nil
+}
+
+// additionalData returns the data the vault contents are authenticated
+// with: the file's identity followed by the raw header bytes
+func (h *VaultHeader) additionalData(headerBytes []byte) []byte {
+	if h.FormatVersion < FormatBound {
+		return headerBytes
+	}
+	ctx := crypto.AADContext{
+		VaultID:       h.VaultID,
+		Role:          roleVault,
+		FormatVersion: h.FormatVersion,
+	}
+	return append(ctx.Bytes(), headerBytes...)
+}
+
+// detectFormat reports which format version a vault file was written in
+func detectFormat(data []byte) uint16 {
+	switch {
+	case bytes.HasPrefix(data, vaultMagic) && len(data) >= len(vaultMagic)+2:
+		return binary.BigEndian.Uint16(data[len(vaultMagic):])
+	case !crypto.IsLegacyCiphertext(data):
+		return FormatKDF
+	default:
+		return FormatLegacy
+	}
+}
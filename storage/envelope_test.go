@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveVault_WritesEnvelopeHeader(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	params, _ := crypto.DefaultKDFParams(crypto.KDFScrypt)
+	if err := store.SetKDFParams(params); err != nil {
+		t.Fatalf("SetKDFParams failed: %v", err)
+	}
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	header, err := NewStorage(tempDir).ReadVaultHeader()
+	if err != nil {
+		t.Fatalf("ReadVaultHeader failed: %v", err)
+	}
+	if header.FormatVersion != CurrentFormatVersion {
+		t.Errorf("Expected format version %d, got %d", CurrentFormatVersion, header.FormatVersion)
+	}
+	if header.CipherSuite != crypto.CipherAES256GCM {
+		t.Errorf("Expected AES-256-GCM, got %s", header.CipherSuite)
+	}
+	if header.Keyslots[0].KDF.Algorithm != crypto.KDFScrypt || string(header.Keyslots[0].KDF.Salt) != string(params.Salt) {
+		t.Errorf("KDF parameters not recorded: got %+v", header.Keyslots[0].KDF)
+	}
+}
+
+func TestLoadVault_HeaderTamperingDetected(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+	data, _ := os.ReadFile(vaultPath)
+
+	// Flip the parallelism byte of the first keyslot's KDF parameters; the
+	// header is authenticated, so this must not open
+	offset := len(vaultMagic) + 6 + 1 + VaultIDSize + 8 + 2 + 9
+	data[offset] ^= 0x01
+	os.WriteFile(vaultPath, data, 0600)
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password")); err == nil {
+		t.Error("LoadVault should reject a vault with a modified header")
+	}
+}
+
+func TestLoadVault_RejectsNewerFormat(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+	data, _ := os.ReadFile(vaultPath)
+	binary.BigEndian.PutUint16(data[len(vaultMagic):], CurrentFormatVersion+1)
+	os.WriteFile(vaultPath, data, 0600)
+
+	_, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password"))
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Expected newer-format error, got %v", err)
+	}
+}
+
+func TestLoadVault_UpgradesKDFFormat(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	params, _ := crypto.DefaultKDFParams(crypto.KDFPBKDF2)
+	plaintext := []byte(`{"entries":[{"id":"1","title":"Old"}],"version":"1.0"}`)
+	encrypted, err := crypto.EncryptDataWithParams(plaintext, crypto.SecretFromString("password"), params, crypto.AADContext{})
+	if err != nil {
+		t.Fatalf("EncryptDataWithParams failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(tempDir, VaultFileName), encrypted, 0600)
+
+	header, err := store.ReadVaultHeader()
+	if err != nil || header.FormatVersion != FormatKDF {
+		t.Fatalf("Expected FormatKDF header, got %+v (%v)", header, err)
+	}
+
+	vault, err := store.LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if len(vault.Entries) != 1 || vault.Entries[0].Title != "Old" {
+		t.Fatalf("Unexpected vault contents: %+v", vault.Entries)
+	}
+
+	header, err = store.ReadVaultHeader()
+	if err != nil {
+		t.Fatalf("ReadVaultHeader failed: %v", err)
+	}
+	if header.FormatVersion != CurrentFormatVersion {
+		t.Errorf("Vault not upgraded: format version %d", header.FormatVersion)
+	}
+	// The upgrade keeps the KDF the vault was already using
+	if header.Keyslots[0].KDF.Algorithm != crypto.KDFPBKDF2 {
+		t.Errorf("Expected pbkdf2 to be kept, got %s", header.Keyslots[0].KDF.Algorithm)
+	}
+}
+
+func TestSaveVault_ConvertCipherSuite(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{
+		Entries: []models.PasswordEntry{{ID: "1", Title: "Entry"}},
+		Version: "1.0",
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	codes, _ := store.GenerateRecoveryCodes(1)
+
+	if err := store.SetCipherSuite(crypto.CipherXChaCha20Poly1305); err != nil {
+		t.Fatalf("SetCipherSuite returned error: %v", err)
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	header, _ := store.ReadVaultHeader()
+	if header.CipherSuite != crypto.CipherXChaCha20Poly1305 {
+		t.Errorf("Expected xchacha20-poly1305 in header, got %s", header.CipherSuite)
+	}
+
+	reopened := NewStorage(tempDir)
+	loaded, err := reopened.LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault failed after conversion: %v", err)
+	}
+	if len(loaded.Entries) != 1 || reopened.CipherSuite() != crypto.CipherXChaCha20Poly1305 {
+		t.Errorf("Unexpected state after conversion: %d entries, suite %s", len(loaded.Entries), reopened.CipherSuite())
+	}
+
+	// Keyslots are independent of the vault cipher
+	if _, err := NewStorage(tempDir).LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[0])); err != nil {
+		t.Errorf("Recovery code should still work after conversion: %v", err)
+	}
+
+	if err := store.SetCipherSuite(crypto.CipherSuite(99)); err == nil {
+		t.Error("SetCipherSuite should reject unknown suites")
+	}
+}
+
+func TestLoadVault_RejectsVaultFromOtherUser(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	dirs := []string{filepath.Join(tempDir, "a"), filepath.Join(tempDir, "b")}
+	for _, dir := range dirs {
+		store := NewStorage(dir)
+		store.Initialize()
+		if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+		if err := store.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveUser failed: %v", err)
+		}
+	}
+
+	// Same password, but a different vault
+	other, _ := os.ReadFile(filepath.Join(dirs[1], VaultFileName))
+	os.WriteFile(filepath.Join(dirs[0], VaultFileName), other, 0600)
+
+	if _, err := NewStorage(dirs[0]).LoadVault(crypto.SecretFromString("password")); !errors.Is(err, ErrVaultMismatch) {
+		t.Errorf("Expected ErrVaultMismatch, got %v", err)
+	}
+}
+
+func TestLoadVault_RejectsRollback(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	if err := store.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+	old, _ := os.ReadFile(vaultPath)
+
+	vault.Entries = append(vault.Entries, models.PasswordEntry{ID: "1", Title: "New"})
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	user, _ := store.LoadUser()
+	header, _ := store.ReadVaultHeader()
+	if user.VaultBinding == nil || user.VaultBinding.Generation != header.Generation {
+		t.Fatalf("User binding not updated: %+v, header generation %d", user.VaultBinding, header.Generation)
+	}
+
+	os.WriteFile(vaultPath, old, 0600)
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password")); !errors.Is(err, ErrVaultRollback) {
+		t.Errorf("Expected ErrVaultRollback, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"passwordmanager/models"
+)
+
+// DefaultHistoryKeep is how many earlier versions of each entry are kept
+// unless configured otherwise
+const DefaultHistoryKeep = 10
+
+// ErrRevisionNotFound is returned for a revision an entry does not have
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// FieldChange is a field that differs between two versions of an entry.
+// Secret fields should not be shown.
+type FieldChange struct {
+	Field  string
+	Old    string
+	New    string
+	Secret bool
+}
+
+// CurrentRevision returns the number of the current version of entry
+func CurrentRevision(entry models.PasswordEntry) int {
+	if n := len(entry.History); n > 0 {
+		return entry.History[n-1].Number + 1
+	}
+	return 1
+}
+
+// CurrentVersion returns the current version of entry as a revision
+func CurrentVersion(entry models.PasswordEntry) models.EntryRevision {
+	return models.EntryRevision{
+		Number:    CurrentRevision(entry),
+		Username:  entry.Username,
+		Password:  entry.Password,
+		URL:       entry.URL,
+		Notes:     entry.Notes,
+		UpdatedAt: entry.UpdatedAt,
+	}
+}
+
+// RecordRevision adds previous, the version of entry before an update, to
+// the history of entry if any of its fields changed, and drops all but the
+// newest keep revisions. A keep of 0 keeps every revision. It reports
+// whether a revision was added.
+func RecordRevision(entry *models.PasswordEntry, previous models.PasswordEntry, keep int) bool {
+	revision := CurrentVersion(previous)
+	if len(DiffRevisions(revision, CurrentVersion(*entry))) == 0 {
+		return false
+	}
+
+	// entry may share its history with previous, so never append in place
+	history := make([]models.EntryRevision, 0, len(previous.History)+1)
+	history = append(history, previous.History...)
+	history = append(history, revision)
+	if keep > 0 && len(history) > keep {
+		history = history[len(history)-keep:]
+	}
+	entry.History = history
+	return true
+}
+
+// FindRevision returns the revision of entry with the given number, which
+// may be the current version
+func FindRevision(entry models.PasswordEntry, number int) (models.EntryRevision, error) {
+	if number == CurrentRevision(entry) {
+		return CurrentVersion(entry), nil
+	}
+	for _, revision := range entry.History {
+		if revision.Number == number {
+			return revision, nil
+		}
+	}
+	return models.EntryRevision{}, ErrRevisionNotFound
+}
+
+// NextRevision returns the version of entry that followed revision number:
+// a newer revision from the history or the current version
+func NextRevision(entry models.PasswordEntry, number int) (models.EntryRevision, error) {
+	for _, revision := range entry.History {
+		if revision.Number > number {
+			return revision, nil
+		}
+	}
+	if number < CurrentRevision(entry) {
+		return CurrentVersion(entry), nil
+	}
+	return models.EntryRevision{}, ErrRevisionNotFound
+}
+
+// RestoreRevision makes revision number the current version of entry. The
+// version it replaces is added to the history, so a restore can be undone.
+func RestoreRevision(entry *models.PasswordEntry, number, keep int) error {
+	revision, err := FindRevision(*entry, number)
+	if err != nil {
+		return err
+	}
+
+	previous := *entry
+	entry.Username = revision.Username
+	entry.Password = revision.Password
+	entry.URL = revision.URL
+	entry.Notes = revision.Notes
+	entry.UpdatedAt = time.Now()
+	RecordRevision(entry, previous, keep)
+	return nil
+}
+
+// DiffRevisions returns the fields that changed from version a to b
+func DiffRevisions(a, b models.EntryRevision) []FieldChange {
+	fields := []FieldChange{
+		{Field: "Username", Old: a.Username, New: b.Username},
+		{Field: "Password", Old: a.Password, New: b.Password, Secret: true},
+		{Field: "URL", Old: a.URL, New: b.URL},
+		{Field: "Notes", Old: a.Notes, New: b.Notes},
+	}
+
+	var changes []FieldChange
+	for _, field := range fields {
+		if field.Old != field.New {
+			changes = append(changes, field)
+		}
+	}
+	return changes
+}
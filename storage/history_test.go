@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"errors"
+	"passwordmanager/models"
+	"testing"
+)
+
+// updateEntry changes the password of entry the way pm update does
+func updateEntry(entry *models.PasswordEntry, password string, keep int) bool {
+	previous := *entry
+	entry.Password = password
+	return RecordRevision(entry, previous, keep)
+}
+
+func TestRecordRevision(t *testing.T) {
+	entry := models.PasswordEntry{ID: "1", Title: "Mail", Password: "one"}
+
+	if updateEntry(&entry, "one", 3) {
+		t.Error("An unchanged entry should not add a revision")
+	}
+	for _, password := range []string{"two", "three", "four", "five"} {
+		if !updateEntry(&entry, password, 3) {
+			t.Fatalf("Changing the password to %s should add a revision", password)
+		}
+	}
+
+	// Only the newest three are kept, with their original numbers
+	if len(entry.History) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(entry.History))
+	}
+	if entry.History[0].Number != 2 || entry.History[0].Password != "two" {
+		t.Errorf("Unexpected oldest revision %+v", entry.History[0])
+	}
+	if CurrentRevision(entry) != 5 {
+		t.Errorf("Expected current revision 5, got %d", CurrentRevision(entry))
+	}
+}
+
+func TestRecordRevision_DoesNotShareHistory(t *testing.T) {
+	entry := models.PasswordEntry{Password: "one"}
+	updateEntry(&entry, "two", 0)
+	entry.History = append(make([]models.EntryRevision, 0, 8), entry.History...)
+
+	copied := entry
+	updateEntry(&entry, "three", 0)
+	updateEntry(&copied, "other", 0)
+	if entry.History[1].Password != "two" || copied.History[1].Password != "two" {
+		t.Errorf("Histories were mixed up: %+v and %+v", entry.History, copied.History)
+	}
+}
+
+func TestRestoreRevision(t *testing.T) {
+	entry := models.PasswordEntry{Username: "me", Password: "one"}
+	updateEntry(&entry, "two", 0)
+
+	if err := RestoreRevision(&entry, 1, 0); err != nil {
+		t.Fatalf("RestoreRevision failed: %v", err)
+	}
+	if entry.Password != "one" || entry.Username != "me" {
+		t.Errorf("Revision 1 was not restored: %+v", entry)
+	}
+	// The restore itself can be undone
+	if len(entry.History) != 2 || entry.History[1].Password != "two" {
+		t.Errorf("Unexpected history %+v", entry.History)
+	}
+
+	if err := RestoreRevision(&entry, 7, 0); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestNextRevision(t *testing.T) {
+	entry := models.PasswordEntry{Password: "one"}
+	updateEntry(&entry, "two", 0)
+	updateEntry(&entry, "three", 0)
+
+	next, err := NextRevision(entry, 1)
+	if err != nil || next.Number != 2 {
+		t.Errorf("Expected revision 2, got %+v (%v)", next, err)
+	}
+	next, err = NextRevision(entry, 2)
+	if err != nil || next.Number != 3 || next.Password != "three" {
+		t.Errorf("Expected the current version, got %+v (%v)", next, err)
+	}
+	if _, err := NextRevision(entry, 3); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("The current version has no next revision, got %v", err)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	a := models.EntryRevision{Username: "me", Password: "one", URL: "u"}
+	b := models.EntryRevision{Username: "me", Password: "two", URL: "v"}
+
+	changes := DiffRevisions(a, b)
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", changes)
+	}
+	if changes[0].Field != "Password" || !changes[0].Secret {
+		t.Errorf("Expected the password change to be secret, got %+v", changes[0])
+	}
+	if changes[1].Field != "URL" || changes[1].Old != "u" || changes[1].New != "v" {
+		t.Errorf("Unexpected change %+v", changes[1])
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"passwordmanager/crypto"
+)
+
+// recoverInterruptedSave deals with temporary files left behind when a
+// SaveVault was interrupted. A temporary file is only renamed after it has
+// been synced, so one that secret opens and that is newer than vault.dat, or
+// stands in for a missing or damaged vault.dat, is put in its place.
+// Truncated, damaged and outdated ones are removed. Ones that secret cannot
+// unlock are left alone, since they cannot be judged.
+func (s *Storage) recoverInterruptedSave(slotType KeyslotType, secret *crypto.Secret) error {
+	temps, err := leftoverTempFiles(s.backend, VaultFileName)
+	if err != nil || len(temps) == 0 {
+		return err
+	}
+
+	current, _, currentErr := s.probeVaultBlob(VaultFileName, slotType, secret)
+	if errors.Is(currentErr, errKeyslotMismatch) {
+		// Most likely the wrong secret; loading vault.dat will report it
+		return nil
+	}
+
+	for _, tmp := range temps {
+		candidate, data, err := s.probeVaultBlob(tmp.Name, slotType, secret)
+		if errors.Is(err, errKeyslotMismatch) {
+			continue
+		}
+		if err != nil ||
+			currentErr == nil && (!bytes.Equal(candidate.VaultID, current.VaultID) || candidate.Generation <= current.Generation) {
+			s.backend.Delete(tmp.Name)
+			continue
+		}
+
+		if _, err := s.backend.Put(VaultFileName, data); err != nil {
+			return fmt.Errorf("failed to restore interrupted save: %w", err)
+		}
+		s.backend.Delete(tmp.Name)
+		current, currentErr = candidate, nil
+	}
+	return nil
+}
+
+// probeVaultBlob checks that the vault blob name is complete and opens with
+// secret, and returns its header and contents
+func (s *Storage) probeVaultBlob(name string, slotType KeyslotType, secret *crypto.Secret) (*VaultHeader, []byte, error) {
+	data, _, err := s.backend.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header, headerBytes, ciphertext, err := parseVaultHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.FormatVersion < FormatBound {
+		return nil, nil, fmt.Errorf("vault format version %d cannot be checked", header.FormatVersion)
+	}
+
+	dataKey, _, err := unlockKeyslots(header.Keyslots, slotType, secret, s.keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer crypto.Wipe(dataKey)
+
+	plaintext, err := header.CipherSuite.Open(dataKey, ciphertext, header.additionalData(headerBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	crypto.Wipe(plaintext)
+	return header, data, nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"passwordmanager/crypto"
+)
+
+// KeyslotType identifies the kind of secret that unlocks a keyslot
+type KeyslotType uint8
+
+const (
+	KeyslotPassword KeyslotType = iota + 1
+	KeyslotKeyFile
+	KeyslotRecovery
+	// KeyslotPasswordKeyFile requires both the master password and a key file
+	KeyslotPasswordKeyFile
+	// KeyslotSharedRecovery is unlocked by a recovery key split into Shamir shares
+	KeyslotSharedRecovery
+)
+
+// String returns a human readable name for the keyslot type
+func (t KeyslotType) String() string {
+	switch t {
+	case KeyslotPassword:
+		return "password"
+	case KeyslotKeyFile:
+		return "key file"
+	case KeyslotRecovery:
+		return "recovery code"
+	case KeyslotPasswordKeyFile:
+		return "password and key file"
+	case KeyslotSharedRecovery:
+		return "shared recovery key"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// Keyslot holds one copy of the vault data key, wrapped under a key
+// derived from a single unlock secret. Wrapping always uses AES-256-GCM,
+// independent of the vault's cipher suite.
+type Keyslot struct {
+	Type       KeyslotType
+	KDF        crypto.KDFParams
+	WrappedKey []byte
+}
+
+// errKeyslotMismatch is returned when a secret does not unwrap a keyslot
+var errKeyslotMismatch = errors.New("secret does not match keyslot")
+
+// newDataKey generates a random vault data encryption key
+func newDataKey() ([]byte, error) {
+	key := make([]byte, crypto.KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// newKeyslot wraps dataKey under a key derived from secret with params.
+// keyFile is only used by KeyslotPasswordKeyFile slots.
+func newKeyslot(slotType KeyslotType, secret, keyFile *crypto.Secret, params crypto.KDFParams, dataKey []byte) (Keyslot, error) {
+	slot := Keyslot{Type: slotType, KDF: params}
+
+	kek, err := slot.deriveKey(secret, keyFile)
+	if err != nil {
+		return Keyslot{}, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer crypto.Wipe(kek)
+
+	aad, err := slot.additionalData()
+	if err != nil {
+		return Keyslot{}, err
+	}
+
+	slot.WrappedKey, err = crypto.Seal(kek, dataKey, aad)
+	if err != nil {
+		return Keyslot{}, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return slot, nil
+}
+
+// unwrap recovers the data key from the slot using secret
+func (k Keyslot) unwrap(secret, keyFile *crypto.Secret) ([]byte, error) {
+	kek, err := k.deriveKey(secret, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer crypto.Wipe(kek)
+
+	aad, err := k.additionalData()
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := crypto.Open(kek, k.WrappedKey, aad)
+	if err != nil {
+		if errors.Is(err, crypto.ErrAuthFailed) {
+			return nil, errKeyslotMismatch
+		}
+		return nil, err
+	}
+	return dataKey, nil
+}
+
+// deriveKey derives the key-encryption key for the slot
+func (k Keyslot) deriveKey(secret, keyFile *crypto.Secret) ([]byte, error) {
+	if k.Type == KeyslotPasswordKeyFile {
+		return crypto.DeriveKeyWithKeyFile(secret, keyFile.Bytes(), k.KDF)
+	}
+	return crypto.DeriveKey(secret, k.KDF)
+}
+
+// additionalData binds the wrapped key to the slot's type and KDF parameters
+func (k Keyslot) additionalData() ([]byte, error) {
+	kdf, err := k.KDF.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(k.Type)}, kdf...), nil
+}
+
+// marshalBinary encodes the slot as
+// type(1) | kdfParams | wrappedLen(2) | wrappedKey
+func (k Keyslot) marshalBinary() ([]byte, error) {
+	meta, err := k.additionalData()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(meta)+2+len(k.WrappedKey))
+	buf = append(buf, meta...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(k.WrappedKey)))
+	return append(buf, k.WrappedKey...), nil
+}
+
+// unmarshalKeyslot decodes a slot written by marshalBinary and returns the
+// number of bytes consumed
+func unmarshalKeyslot(data []byte) (Keyslot, int, error) {
+	if len(data) < 1 {
+		return Keyslot{}, 0, fmt.Errorf("keyslot truncated")
+	}
+	slot := Keyslot{Type: KeyslotType(data[0])}
+
+	kdf, n, err := crypto.UnmarshalKDFParams(data[1:])
+	if err != nil {
+		return Keyslot{}, 0, err
+	}
+	slot.KDF = kdf
+
+	offset := 1 + n
+	if len(data) < offset+2 {
+		return Keyslot{}, 0, fmt.Errorf("keyslot truncated")
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(data[offset:]))
+	offset += 2
+	if len(data) < offset+wrappedLen {
+		return Keyslot{}, 0, fmt.Errorf("keyslot truncated")
+	}
+	slot.WrappedKey = append([]byte(nil), data[offset:offset+wrappedLen]...)
+	return slot, offset + wrappedLen, nil
+}
+
+// unlockKeyslots tries every slot of the given type with secret and returns
+// the data key and index of the first that opens
+func unlockKeyslots(slots []Keyslot, slotType KeyslotType, secret, keyFile *crypto.Secret) ([]byte, int, error) {
+	for i, slot := range slots {
+		if slot.Type != slotType {
+			continue
+		}
+		dataKey, err := slot.unwrap(secret, keyFile)
+		if errors.Is(err, errKeyslotMismatch) {
+			continue
+		}
+		return dataKey, i, err
+	}
+	return nil, -1, errKeyslotMismatch
+}
+
+// hasKeyslot reports whether any slot has the given type
+func hasKeyslot(slots []Keyslot, slotType KeyslotType) bool {
+	for _, slot := range slots {
+		if slot.Type == slotType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+)
+
+func TestAddKeyslot_UnlocksSameDataKey(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{
+		Entries: []models.PasswordEntry{{ID: "1", Title: "Entry"}},
+		Version: "1.0",
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	params, _ := crypto.DefaultKDFParams(crypto.KDFArgon2id)
+	if err := store.AddKeyslot(KeyslotRecovery, crypto.SecretFromString("recovery-secret"), params); err != nil {
+		t.Fatalf("AddKeyslot returned error: %v", err)
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	header, _ := store.ReadVaultHeader()
+	if len(header.Keyslots) != 2 {
+		t.Fatalf("Expected 2 keyslots, got %d", len(header.Keyslots))
+	}
+
+	recovered, err := NewStorage(tempDir).loadVault(KeyslotRecovery, crypto.SecretFromString("recovery-secret"))
+	if err != nil {
+		t.Fatalf("Recovery keyslot should open the vault: %v", err)
+	}
+	if len(recovered.Entries) != 1 {
+		t.Errorf("Unexpected vault contents: %+v", recovered.Entries)
+	}
+
+	// A password must not be accepted by a slot of another type
+	if _, err := NewStorage(tempDir).loadVault(KeyslotRecovery, crypto.SecretFromString("password")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Expected ErrInvalidPassword, got %v", err)
+	}
+
+	if err := store.RemoveKeyslots(KeyslotRecovery); err != nil {
+		t.Fatalf("RemoveKeyslots returned error: %v", err)
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	if _, err := NewStorage(tempDir).loadVault(KeyslotRecovery, crypto.SecretFromString("recovery-secret")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Removed keyslot still opens the vault: %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password")); err != nil {
+		t.Errorf("Password keyslot should still open the vault: %v", err)
+	}
+}
+
+func TestAddKeyslot_RequiresUnlock(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	params, _ := crypto.DefaultKDFParams(crypto.KDFArgon2id)
+
+	if err := store.AddKeyslot(KeyslotRecovery, crypto.SecretFromString("secret"), params); !errors.Is(err, ErrVaultLocked) {
+		t.Errorf("Expected ErrVaultLocked, got %v", err)
+	}
+}
+
+func TestLoadVault_CorruptedBodyDistinguishedFromWrongPassword(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+	data, _ := os.ReadFile(vaultPath)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(vaultPath, data, 0600)
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password")); !errors.Is(err, ErrVaultCorrupted) {
+		t.Errorf("Expected ErrVaultCorrupted, got %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("wrong")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Expected ErrInvalidPassword, got %v", err)
+	}
+}
+
+func TestLoadVault_UpgradesEnvelopeToKeyslots(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	// Write a FormatEnvelope vault encrypted directly under the password key
+	params, _ := crypto.DefaultKDFParams(crypto.KDFArgon2id)
+	header := &VaultHeader{FormatVersion: FormatEnvelope, CipherSuite: crypto.CipherAES256GCM, KDF: params}
+	headerBytes, err := header.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	key, _ := crypto.DeriveKey(crypto.SecretFromString("password"), params)
+	ciphertext, _ := crypto.Seal(key, []byte(`{"entries":[{"id":"1","title":"Old"}],"version":"1.0"}`), headerBytes)
+	os.WriteFile(filepath.Join(tempDir, VaultFileName), append(headerBytes, ciphertext...), 0600)
+
+	vault, err := store.LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if len(vault.Entries) != 1 || vault.Entries[0].Title != "Old" {
+		t.Fatalf("Unexpected vault contents: %+v", vault.Entries)
+	}
+
+	upgraded, err := store.ReadVaultHeader()
+	if err != nil {
+		t.Fatalf("ReadVaultHeader failed: %v", err)
+	}
+	if upgraded.FormatVersion != CurrentFormatVersion || len(upgraded.Keyslots) != 1 {
+		t.Fatalf("Vault not upgraded to keyslots: %+v", upgraded)
+	}
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password")); err != nil {
+		t.Errorf("Upgraded vault could not be opened: %v", err)
+	}
+}
+
+func TestKeyFile_RequiredToUnlock(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	keyFile := []byte("0123456789abcdef0123456789abcdef")
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+	store.SetKeyFile(keyFile)
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	header, _ := store.ReadVaultHeader()
+	if len(header.Keyslots) != 1 || header.Keyslots[0].Type != KeyslotPasswordKeyFile {
+		t.Fatalf("Expected a single password+key file keyslot, got %+v", header.Keyslots)
+	}
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password")); !errors.Is(err, ErrKeyFileRequired) {
+		t.Errorf("Expected ErrKeyFileRequired, got %v", err)
+	}
+
+	wrongFile := NewStorage(tempDir)
+	wrongFile.SetKeyFile([]byte("not the right key file"))
+	if _, err := wrongFile.LoadVault(crypto.SecretFromString("password")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Expected ErrInvalidPassword for wrong key file, got %v", err)
+	}
+
+	rightFile := NewStorage(tempDir)
+	rightFile.SetKeyFile(keyFile)
+	if _, err := rightFile.LoadVault(crypto.SecretFromString("wrong")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Expected ErrInvalidPassword for wrong password, got %v", err)
+	}
+	loaded, err := rightFile.LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault with password and key file failed: %v", err)
+	}
+
+	// Changing the password keeps the key file requirement
+	if err := rightFile.ChangeMasterPassword(loaded, crypto.SecretFromString("newpassword")); err != nil {
+		t.Fatalf("ChangeMasterPassword failed: %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("newpassword")); !errors.Is(err, ErrKeyFileRequired) {
+		t.Errorf("Expected ErrKeyFileRequired after password change, got %v", err)
+	}
+}
+
+func TestKeyFile_NotUsedByVault(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	withFile := NewStorage(tempDir)
+	withFile.SetKeyFile([]byte("some key file"))
+	if _, err := withFile.LoadVault(crypto.SecretFromString("password")); !errors.Is(err, ErrKeyFileNotUsed) {
+		t.Errorf("Expected ErrKeyFileNotUsed, got %v", err)
+	}
+}
+
+func TestStorage_WipeLocksVault(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	store.Wipe()
+
+	params, _ := crypto.RecoveryKDFParams()
+	if err := store.AddKeyslot(KeyslotRecovery, crypto.SecretFromString("secret"), params); !errors.Is(err, ErrVaultLocked) {
+		t.Errorf("Expected ErrVaultLocked after Wipe, got %v", err)
+	}
+	if _, err := store.LoadVault(crypto.SecretFromString("password")); err != nil {
+		t.Errorf("LoadVault after Wipe failed: %v", err)
+	}
+}
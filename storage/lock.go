@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFileName is the advisory lock file guarding the data directory
+const LockFileName = "pm.lock"
+
+// DefaultLockTimeout is how long Lock waits for another process by default
+const DefaultLockTimeout = 10 * time.Second
+
+// lockPollInterval is how often Lock retries while the vault is busy
+const lockPollInterval = 100 * time.Millisecond
+
+// ErrVaultBusy is returned when another process holds the vault lock for
+// longer than the lock timeout
+var ErrVaultBusy = errors.New("vault is busy")
+
+// SetLockTimeout sets how long Lock waits for another process to release
+// the vault
+func (s *Storage) SetLockTimeout(timeout time.Duration) {
+	s.lockTimeout = timeout
+}
+
+// Lock takes the advisory lock on the data directory, so that a load,
+// modify and save cycle is not interleaved with another pm process. It
+// waits up to the lock timeout and then fails with ErrVaultBusy. A lock
+// left behind by a process that no longer runs is broken. Calls nest:
+// each Lock needs a matching Unlock. A store without a data directory only
+// counts the nesting.
+func (s *Storage) Lock() error {
+	if s.lockDepth > 0 || s.dataDir == "" {
+		s.lockDepth++
+		return nil
+	}
+
+	lockPath := filepath.Join(s.dataDir, LockFileName)
+	owner := lockOwner()
+	deadline := time.Now().Add(s.lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return fmt.Errorf("failed to write lock file: %w", err)
+			}
+			s.lockDepth = 1
+			return nil
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		info, err := os.Stat(lockPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read lock file: %w", err)
+		}
+		holder, err := os.ReadFile(lockPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read lock file: %w", err)
+		}
+		pid, alive := lockHolderAlive(string(holder), info.ModTime())
+		if !alive {
+			// Only remove the lock if it is still the stale one
+			if current, err := os.ReadFile(lockPath); err == nil && string(current) == string(holder) {
+				os.Remove(lockPath)
+			}
+			continue
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: locked by process %d (%s)", ErrVaultBusy, pid, lockPath)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Unlock releases a lock taken with Lock
+func (s *Storage) Unlock() error {
+	if s.lockDepth == 0 {
+		return fmt.Errorf("vault is not locked")
+	}
+	s.lockDepth--
+	if s.lockDepth > 0 || s.dataDir == "" {
+		return nil
+	}
+	return os.Remove(filepath.Join(s.dataDir, LockFileName))
+}
+
+// lockOwner returns the contents written to the lock file: the process ID
+// and host name of this process
+func lockOwner() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%d %s\n", os.Getpid(), hostname)
+}
+
+// lockHolderAlive parses lock file contents and reports the holder's process
+// ID and whether it may still be running. Locks taken on another host, as on
+// a network file system, cannot be checked and are assumed to be alive.
+func lockHolderAlive(contents string, modTime time.Time) (int, bool) {
+	fields := strings.Fields(contents)
+	if len(fields) == 0 {
+		// The holder may not have written its PID yet; give it a moment
+		return 0, time.Since(modTime) < time.Second
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+
+	hostname, _ := os.Hostname()
+	if len(fields) > 1 && fields[1] != hostname {
+		return pid, true
+	}
+	return pid, processAlive(pid)
+}
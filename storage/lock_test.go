@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+	"time"
+)
+
+func TestLock_Exclusive(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	first := NewStorage(tempDir)
+	first.Initialize()
+	if err := first.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	second := NewStorage(tempDir)
+	second.SetLockTimeout(200 * time.Millisecond)
+	if err := second.Lock(); !errors.Is(err, ErrVaultBusy) {
+		t.Fatalf("Expected ErrVaultBusy, got %v", err)
+	}
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := second.SaveVault(vault, crypto.SecretFromString("password")); !errors.Is(err, ErrVaultBusy) {
+		t.Errorf("SaveVault should fail while another store holds the lock, got %v", err)
+	}
+
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := second.Lock(); err != nil {
+		t.Fatalf("Lock after release failed: %v", err)
+	}
+	second.Unlock()
+}
+
+func TestLock_Nested(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+	lockPath := filepath.Join(tempDir, LockFileName)
+
+	store.Lock()
+	store.Lock()
+	store.Unlock()
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Error("Lock released before the outermost Unlock")
+	}
+	store.Unlock()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Error("Lock file should be removed by the outermost Unlock")
+	}
+
+	if err := store.Unlock(); err == nil {
+		t.Error("Unlock without Lock should fail")
+	}
+}
+
+func TestLock_BreaksStaleLock(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Run a process to completion to get a PID that is no longer in use
+	proc := exec.Command(os.Args[0], "-test.run=^$")
+	if err := proc.Run(); err != nil {
+		t.Fatalf("Failed to run helper process: %v", err)
+	}
+	hostname, _ := os.Hostname()
+	stale := fmt.Sprintf("%d %s\n", proc.Process.Pid, hostname)
+	os.WriteFile(filepath.Join(tempDir, LockFileName), []byte(stale), 0600)
+
+	store := NewStorage(tempDir)
+	store.SetLockTimeout(200 * time.Millisecond)
+	if err := store.Lock(); err != nil {
+		t.Fatalf("Lock should break a stale lock, got %v", err)
+	}
+	store.Unlock()
+}
+
+func TestLockHolderAlive(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	if _, alive := lockHolderAlive(fmt.Sprintf("%d %s\n", os.Getpid(), hostname), time.Now()); !alive {
+		t.Error("The current process should be alive")
+	}
+	if _, alive := lockHolderAlive(fmt.Sprintf("%d other-host\n", 1<<30), time.Now()); !alive {
+		t.Error("Locks from another host should be assumed alive")
+	}
+	if _, alive := lockHolderAlive("garbage", time.Now()); alive {
+		t.Error("Unparsable lock files should be treated as stale")
+	}
+	if _, alive := lockHolderAlive("", time.Now()); !alive {
+		t.Error("A freshly created empty lock file should be respected")
+	}
+	if _, alive := lockHolderAlive("", time.Now().Add(-time.Minute)); alive {
+		t.Error("An old empty lock file should be treated as stale")
+	}
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+)
+
+// CurrentVaultVersion is the schema version of models.PasswordVault written
+// by this version of pm. Vaults recorded without a version are treated as
+// version 1.0, the first schema.
+const CurrentVaultVersion = "1.5"
+
+// ErrVaultTooNew is returned for a vault written by a newer version of pm
+// that this version cannot upgrade to its own schema
+var ErrVaultTooNew = errors.New("vault was written by a newer version of pm")
+
+// VaultMigration upgrades the decoded vault JSON from one schema version to
+// the next
+type VaultMigration struct {
+	From        string
+	To          string
+	Description string
+
+	// Migrate changes vault in place. Numbers are json.Number; the version
+	// is updated afterwards.
+	Migrate func(vault map[string]any) error
+}
+
+// vaultMigrations lists every migration in order: each starts at the
+// version the one before it ends at, and the last ends at
+// CurrentVaultVersion. Changing PasswordVault in a way older vaults cannot
+// be decoded into needs a new migration and a new CurrentVaultVersion.
+var vaultMigrations = []VaultMigration{
+	{
+		From:        "1.0",
+		To:          "1.1",
+		Description: "entries keep their earlier versions in history",
+		// The history field is new and optional, so there is nothing to
+		// convert; the new version stops older versions of pm from saving
+		// the vault without it
+		Migrate: func(vault map[string]any) error { return nil },
+	},
+	{
+		From:        "1.1",
+		To:          "1.2",
+		Description: "deleted entries are kept in the trash",
+		Migrate:     func(vault map[string]any) error { return nil },
+	},
+	{
+		From:        "1.2",
+		To:          "1.3",
+		Description: "entry IDs are UUIDs",
+		Migrate:     migrateEntryIDs,
+	},
+	{
+		From:        "1.3",
+		To:          "1.4",
+		Description: "entries can be filed in folders",
+		Migrate:     func(vault map[string]any) error { return nil },
+	},
+	{
+		From:        "1.4",
+		To:          "1.5",
+		Description: "entries can be tagged",
+		Migrate:     func(vault map[string]any) error { return nil },
+	},
+}
+
+// MigrationPlan returns the migrations that upgrade a vault of the given
+// version to CurrentVaultVersion, in the order they are applied
+func MigrationPlan(version string) ([]VaultMigration, error) {
+	return planMigrations(vaultMigrations, version, CurrentVaultVersion)
+}
+
+// planMigrations returns the migrations leading from version to target
+func planMigrations(migrations []VaultMigration, version, target string) ([]VaultMigration, error) {
+	if version == "" {
+		version = "1.0"
+	}
+
+	var plan []VaultMigration
+	current := version
+	for _, m := range migrations {
+		if current == target {
+			break
+		}
+		if m.From == current {
+			plan = append(plan, m)
+			current = m.To
+		}
+	}
+	if current == target {
+		return plan, nil
+	}
+
+	if newerVersion(version, target) {
+		return nil, fmt.Errorf("%w: vault version %s, this version of pm supports up to %s", ErrVaultTooNew, version, target)
+	}
+	return nil, fmt.Errorf("unknown vault version %q", version)
+}
+
+// newerVersion reports whether version a, such as "1.2", is newer than b.
+// Versions that cannot be parsed are never newer.
+func newerVersion(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		var err error
+		if i < len(as) {
+			if x, err = strconv.Atoi(as[i]); err != nil {
+				return false
+			}
+		}
+		if i < len(bs) {
+			if y, err = strconv.Atoi(bs[i]); err != nil {
+				return false
+			}
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+// migrateVault upgrades decrypted vault JSON to target and returns the
+// result with the migrations applied. Without any to apply data itself is
+// returned; otherwise the result is a new slice the caller must wipe.
+func migrateVault(data []byte, migrations []VaultMigration, target string) ([]byte, []VaultMigration, error) {
+	var stored struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal vault: %w", err)
+	}
+
+	plan, err := planMigrations(migrations, stored.Version, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(plan) == 0 {
+		return data, nil, nil
+	}
+
+	var vault map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&vault); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal vault: %w", err)
+	}
+
+	for _, m := range plan {
+		if err := m.Migrate(vault); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate vault from version %s to %s: %w", m.From, m.To, err)
+		}
+		vault["version"] = m.To
+	}
+
+	migrated, err := json.Marshal(vault)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal migrated vault: %w", err)
+	}
+	return migrated, plan, nil
+}
+
+// PendingMigrations returns the migrations applied to the vault when it was
+// loaded that have not been saved yet. The next SaveVault writes the vault
+// in CurrentVaultVersion.
+func (s *Storage) PendingMigrations() []VaultMigration {
+	return append([]VaultMigration(nil), s.pendingMigrations...)
+}
+
+// decodeVault unmarshals decrypted vault JSON, upgrading it to
+// CurrentVaultVersion, and wipes it. It returns the migrations applied.
+func decodeVault(data []byte) (*models.PasswordVault, []VaultMigration, error) {
+	defer crypto.Wipe(data)
+
+	migrated, applied, err := migrateVault(data, vaultMigrations, CurrentVaultVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(applied) > 0 {
+		defer crypto.Wipe(migrated)
+	}
+
+	var vault models.PasswordVault
+	if err := json.Unmarshal(migrated, &vault); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal vault: %w", err)
+	}
+	return &vault, applied, nil
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+)
+
+// testMigrations rename the notes field of every entry to comment and back
+var testMigrations = []VaultMigration{
+	{From: "1.0", To: "1.1", Description: "rename notes to comment", Migrate: func(vault map[string]any) error {
+		return renameEntryField(vault, "notes", "comment")
+	}},
+	{From: "1.1", To: "2.0", Description: "rename comment to notes", Migrate: func(vault map[string]any) error {
+		return renameEntryField(vault, "comment", "notes")
+	}},
+}
+
+func renameEntryField(vault map[string]any, from, to string) error {
+	entries, ok := vault["entries"].([]any)
+	if !ok {
+		return errors.New("entries is not a list")
+	}
+	for _, e := range entries {
+		entry := e.(map[string]any)
+		entry[to] = entry[from]
+		delete(entry, from)
+	}
+	return nil
+}
+
+func TestPlanMigrations(t *testing.T) {
+	plan, err := planMigrations(testMigrations, "1.0", "2.0")
+	if err != nil {
+		t.Fatalf("planMigrations failed: %v", err)
+	}
+	if len(plan) != 2 || plan[0].To != "1.1" || plan[1].To != "2.0" {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+
+	// A vault without a version is the first schema
+	plan, err = planMigrations(testMigrations, "", "1.1")
+	if err != nil || len(plan) != 1 {
+		t.Errorf("Expected one migration, got %+v (%v)", plan, err)
+	}
+
+	plan, err = planMigrations(testMigrations, "2.0", "2.0")
+	if err != nil || len(plan) != 0 {
+		t.Errorf("Expected no migrations, got %+v (%v)", plan, err)
+	}
+}
+
+func TestPlanMigrations_TooNew(t *testing.T) {
+	for _, version := range []string{"2.1", "10.0", "2.0.1"} {
+		if _, err := planMigrations(testMigrations, version, "2.0"); !errors.Is(err, ErrVaultTooNew) {
+			t.Errorf("Version %s: expected ErrVaultTooNew, got %v", version, err)
+		}
+	}
+
+	_, err := planMigrations(testMigrations, "0.5", "2.0")
+	if err == nil || errors.Is(err, ErrVaultTooNew) {
+		t.Errorf("Expected an unknown version error, got %v", err)
+	}
+}
+
+func TestMigrateVault(t *testing.T) {
+	data := []byte(`{"entries":[{"id":"1","title":"Mail","notes":"n"}],"version":"1.0","revision":18446744073709551615}`)
+
+	migrated, applied, err := migrateVault(data, testMigrations, "1.1")
+	if err != nil {
+		t.Fatalf("migrateVault failed: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("Expected one migration, got %d", len(applied))
+	}
+
+	var vault struct {
+		Entries  []map[string]string `json:"entries"`
+		Version  string              `json:"version"`
+		Revision uint64              `json:"revision"`
+	}
+	if err := json.Unmarshal(migrated, &vault); err != nil {
+		t.Fatalf("Migrated vault is invalid: %v", err)
+	}
+	if vault.Version != "1.1" || vault.Entries[0]["comment"] != "n" {
+		t.Errorf("Unexpected migrated vault %s", migrated)
+	}
+	// Large numbers survive the round trip
+	if vault.Revision != 18446744073709551615 {
+		t.Errorf("Revision changed to %d", vault.Revision)
+	}
+
+	// Nothing to do returns the data unchanged
+	same, applied, err := migrateVault(data, testMigrations, "1.0")
+	if err != nil || len(applied) != 0 || &same[0] != &data[0] {
+		t.Errorf("Expected the data unchanged, got %s (%v)", same, err)
+	}
+}
+
+func TestMigrateVault_Failure(t *testing.T) {
+	data := []byte(`{"entries":null,"version":"1.0"}`)
+	if _, _, err := migrateVault(data, testMigrations, "2.0"); err == nil {
+		t.Error("migrateVault should report a failed migration")
+	}
+}
+
+func TestSaveVault_WritesCurrentVersion(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}}
+	if err := store.SaveVault(vault, password); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	if vault.Version != CurrentVaultVersion {
+		t.Errorf("Expected version %s, got %q", CurrentVaultVersion, vault.Version)
+	}
+
+	loaded, err := NewStorage(tempDir).LoadVault(password)
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if loaded.Version != CurrentVaultVersion {
+		t.Errorf("Expected version %s, got %q", CurrentVaultVersion, loaded.Version)
+	}
+}
+
+func TestVaultMigrations_Chain(t *testing.T) {
+	// The registry must lead from the first schema to the current one
+	plan, err := MigrationPlan("1.0")
+	if err != nil {
+		t.Fatalf("MigrationPlan failed: %v", err)
+	}
+	if len(plan) != len(vaultMigrations) {
+		t.Errorf("Expected all %d migrations to apply, got %d", len(vaultMigrations), len(plan))
+	}
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+)
+
+// GenerateRecoveryCodes replaces every recovery keyslot of the unlocked vault
+// with n new ones and returns their codes. The codes are not stored anywhere
+// and must be shown to the user. The change is written by the next SaveVault.
+func (s *Storage) GenerateRecoveryCodes(n int) ([]string, error) {
+	if s.dataKey == nil {
+		return nil, ErrVaultLocked
+	}
+	if err := s.RemoveKeyslots(KeyslotRecovery); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		code, err := crypto.GenerateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		params, err := crypto.RecoveryKDFParams()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate KDF parameters: %w", err)
+		}
+		slotSecret := crypto.SecretFromString(code)
+		if err := crypto.NormalizeRecoveryCode(slotSecret); err != nil {
+			slotSecret.Wipe()
+			return nil, err
+		}
+		err = s.AddKeyslot(KeyslotRecovery, slotSecret, params)
+		slotSecret.Wipe()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// RecoveryCodeCount returns the number of unused recovery codes
+func (s *Storage) RecoveryCodeCount() int {
+	count := 0
+	for _, slot := range s.keyslots {
+		if slot.Type == KeyslotRecovery {
+			count++
+		}
+	}
+	return count
+}
+
+// LoadVaultWithRecoveryCode opens the vault with a recovery code instead of
+// the master password. Follow it with RecoverMasterPassword to use up the code.
+// The code is normalized in place; the caller still wipes it.
+func (s *Storage) LoadVaultWithRecoveryCode(code *crypto.Secret) (*models.PasswordVault, error) {
+	if err := crypto.NormalizeRecoveryCode(code); err != nil {
+		return nil, err
+	}
+	return s.loadVault(KeyslotRecovery, code)
+}
+
+// GenerateSharedRecoveryKey replaces the shared recovery keyslot of the
+// unlocked vault with a new one and returns its random key, which the caller
+// splits into shares. The change is written by the next SaveVault.
+func (s *Storage) GenerateSharedRecoveryKey() ([]byte, error) {
+	if s.dataKey == nil {
+		return nil, ErrVaultLocked
+	}
+	if err := s.RemoveKeyslots(KeyslotSharedRecovery); err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, crypto.KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate recovery key: %w", err)
+	}
+
+	params, err := crypto.RecoveryKDFParams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate KDF parameters: %w", err)
+	}
+	slotSecret := sharedRecoverySecret(key)
+	defer slotSecret.Wipe()
+	if err := s.AddKeyslot(KeyslotSharedRecovery, slotSecret, params); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LoadVaultWithSharedRecoveryKey opens the vault with a recovery key
+// reconstructed from Shamir shares. Follow it with RecoverMasterPassword to
+// use up the key.
+func (s *Storage) LoadVaultWithSharedRecoveryKey(key []byte) (*models.PasswordVault, error) {
+	slotSecret := sharedRecoverySecret(key)
+	defer slotSecret.Wipe()
+	return s.loadVault(KeyslotSharedRecovery, slotSecret)
+}
+
+// sharedRecoverySecret returns the keyslot secret for a shared recovery key,
+// its hex encoding
+func sharedRecoverySecret(key []byte) *crypto.Secret {
+	encoded := make([]byte, hex.EncodedLen(len(key)))
+	hex.Encode(encoded, key)
+	return crypto.NewSecret(encoded)
+}
+
+// RecoverMasterPassword sets a new master password on a vault opened with
+// LoadVaultWithRecoveryCode or LoadVaultWithSharedRecoveryKey and removes
+// the recovery keyslot that was used, in a single atomic write.
+func (s *Storage) RecoverMasterPassword(vault *models.PasswordVault, newPassword *crypto.Secret) error {
+	if s.dataKey == nil {
+		return ErrVaultLocked
+	}
+	if s.unlockedSlot < 0 || s.unlockedSlot >= len(s.keyslots) {
+		return fmt.Errorf("vault was not opened with a recovery code")
+	}
+	if slotType := s.keyslots[s.unlockedSlot].Type; slotType != KeyslotRecovery && slotType != KeyslotSharedRecovery {
+		return fmt.Errorf("vault was not opened with a recovery code")
+	}
+
+	s.keyslots = append(s.keyslots[:s.unlockedSlot:s.unlockedSlot], s.keyslots[s.unlockedSlot+1:]...)
+	s.unlockedSlot = -1
+
+	return s.ChangeMasterPassword(vault, newPassword)
+}
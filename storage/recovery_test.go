@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"errors"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+)
+
+func TestRecoveryCodes_ResetMasterPassword(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{
+		Entries: []models.PasswordEntry{{ID: "1", Title: "Entry"}},
+		Version: "1.0",
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("forgotten")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	codes, err := store.GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes returned error: %v", err)
+	}
+	if len(codes) != 3 {
+		t.Fatalf("Expected 3 codes, got %d", len(codes))
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("forgotten")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	recovering := NewStorage(tempDir)
+	recovered, err := recovering.LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[1]))
+	if err != nil {
+		t.Fatalf("LoadVaultWithRecoveryCode returned error: %v", err)
+	}
+	if len(recovered.Entries) != 1 {
+		t.Fatalf("Unexpected vault contents: %+v", recovered.Entries)
+	}
+	if err := recovering.RecoverMasterPassword(recovered, crypto.SecretFromString("newpassword")); err != nil {
+		t.Fatalf("RecoverMasterPassword returned error: %v", err)
+	}
+	if recovering.RecoveryCodeCount() != 2 {
+		t.Errorf("Expected 2 remaining codes, got %d", recovering.RecoveryCodeCount())
+	}
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("forgotten")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Old password should no longer work, got %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("newpassword")); err != nil {
+		t.Errorf("New password should open the vault: %v", err)
+	}
+
+	// The used code is gone, the others still work
+	if _, err := NewStorage(tempDir).LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[1])); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Used recovery code should be rejected, got %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[0])); err != nil {
+		t.Errorf("Unused recovery code should still work: %v", err)
+	}
+}
+
+func TestGenerateRecoveryCodes_ReplacesOldCodes(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	oldCodes, _ := store.GenerateRecoveryCodes(2)
+	newCodes, _ := store.GenerateRecoveryCodes(2)
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	if store.RecoveryCodeCount() != 2 {
+		t.Errorf("Expected 2 codes, got %d", store.RecoveryCodeCount())
+	}
+	if _, err := NewStorage(tempDir).LoadVaultWithRecoveryCode(crypto.SecretFromString(oldCodes[0])); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Replaced recovery code should be rejected, got %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVaultWithRecoveryCode(crypto.SecretFromString(newCodes[0])); err != nil {
+		t.Errorf("New recovery code should work: %v", err)
+	}
+}
+
+func TestRecoverMasterPassword_RequiresRecoveryUnlock(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	opened := NewStorage(tempDir)
+	loaded, err := opened.LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if err := opened.RecoverMasterPassword(loaded, crypto.SecretFromString("other")); err == nil {
+		t.Error("RecoverMasterPassword should require a vault opened with a recovery code")
+	}
+}
+
+func TestSharedRecoveryKey(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	codes, _ := store.GenerateRecoveryCodes(1)
+	key, err := store.GenerateSharedRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateSharedRecoveryKey returned error: %v", err)
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	recovering := NewStorage(tempDir)
+	recovered, err := recovering.LoadVaultWithSharedRecoveryKey(key)
+	if err != nil {
+		t.Fatalf("LoadVaultWithSharedRecoveryKey returned error: %v", err)
+	}
+	if err := recovering.RecoverMasterPassword(recovered, crypto.SecretFromString("newpassword")); err != nil {
+		t.Fatalf("RecoverMasterPassword returned error: %v", err)
+	}
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("newpassword")); err != nil {
+		t.Errorf("New password should open the vault: %v", err)
+	}
+	if _, err := NewStorage(tempDir).LoadVaultWithSharedRecoveryKey(key); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Used shared recovery key should be rejected, got %v", err)
+	}
+
+	// Individual recovery codes are independent of the shared key
+	if _, err := NewStorage(tempDir).LoadVaultWithRecoveryCode(crypto.SecretFromString(codes[0])); err != nil {
+		t.Errorf("Recovery code should be unaffected: %v", err)
+	}
+}
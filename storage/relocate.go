@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RelocateDataDir moves the data directory from to the path to, which must
+// not exist yet. The lock on from is held while moving, so no other pm
+// process is halfway through a save when the directory goes away.
+func RelocateDataDir(from, to string) error {
+	store := NewStorage(from)
+	if err := store.Lock(); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(to); err == nil {
+		store.Unlock()
+		return fmt.Errorf("%s already exists", to)
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+		store.Unlock()
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(to), err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		store.Unlock()
+		return fmt.Errorf("failed to move data directory: %w", err)
+	}
+
+	// The lock file moved along with the directory
+	store.dataDir = to
+	if err := store.Unlock(); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(to))
+}
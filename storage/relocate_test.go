@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+	"time"
+)
+
+func TestRelocateDataDir(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	from := filepath.Join(tempDir, "legacy")
+	to := filepath.Join(tempDir, "xdg", "share", "passwordmanager")
+	password := crypto.SecretFromString("password")
+
+	store := NewStorage(from)
+	store.Initialize()
+	vault := &models.PasswordVault{
+		Entries: []models.PasswordEntry{{ID: "1", Title: "Mail"}},
+		Version: "1.0",
+	}
+	if err := store.SaveVault(vault, password); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	if err := store.SaveUser(&models.User{CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	if err := RelocateDataDir(from, to); err != nil {
+		t.Fatalf("RelocateDataDir failed: %v", err)
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Error("The old data directory should be gone")
+	}
+	if _, err := os.Stat(filepath.Join(to, LockFileName)); !os.IsNotExist(err) {
+		t.Error("The lock should be released after moving")
+	}
+
+	loaded, err := NewStorage(to).LoadVault(password)
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if len(loaded.Entries) != 1 || loaded.Entries[0].Title != "Mail" {
+		t.Errorf("Unexpected entries: %+v", loaded.Entries)
+	}
+}
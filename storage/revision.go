@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+)
+
+var (
+	// ErrVaultConflict is returned by SaveVaultChecked when the vault was
+	// saved by someone else since it was loaded
+	ErrVaultConflict = errors.New("vault was changed by another pm command")
+
+	// ErrEntryConflict is returned by EntryChange.Apply when the entry itself
+	// was changed or removed in the meantime, or another entry took its path
+	ErrEntryConflict = errors.New("entry was changed by another pm command")
+)
+
+// ConflictError is returned by SaveVaultChecked. Latest is the vault as it is
+// now on disk, so the caller can reapply its change to it.
+type ConflictError struct {
+	Latest *models.PasswordVault
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v (now at revision %d)", ErrVaultConflict, e.Latest.Revision)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrVaultConflict
+}
+
+// SaveVaultChecked saves vault like SaveVault, but only if the vault on disk
+// is still at vault.Revision. Otherwise nothing is written and a
+// *ConflictError holding the newer vault is returned.
+func (s *Storage) SaveVaultChecked(vault *models.PasswordVault, masterPassword *crypto.Secret) error {
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	if s.VaultExists() {
+		header, err := s.ReadVaultHeader()
+		if err != nil {
+			return err
+		}
+		// Every save bumps the header generation, so an unchanged one means
+		// vault.dat is still the file vault was loaded from
+		if header.FormatVersion < FormatBound || header.Generation != s.generation {
+			latest, err := s.LoadVault(masterPassword)
+			if err != nil {
+				return err
+			}
+			if latest.Revision != vault.Revision {
+				return &ConflictError{Latest: latest}
+			}
+		}
+	}
+
+	return s.SaveVault(vault, masterPassword)
+}
+
+// EntryChange describes an edit to a single entry, so that it can be
+// reapplied to a newer vault after a conflict. Before is nil for an added
+// entry and After is nil for a deleted one. Trashed is the deleted entry as
+// it was moved to the trash, if it was.
+type EntryChange struct {
+	Before  *models.PasswordEntry
+	After   *models.PasswordEntry
+	Trashed *models.TrashedEntry
+}
+
+// Apply makes the change to vault. It fails with ErrEntryConflict if the
+// entry in vault is no longer the one the change was made to, or if an added
+// or renamed entry would share its folder and title with another.
+func (c EntryChange) Apply(vault *models.PasswordVault) error {
+	id := ""
+	switch {
+	case c.Before != nil:
+		id = c.Before.ID
+	case c.After != nil:
+		id = c.After.ID
+	default:
+		return nil
+	}
+
+	index := -1
+	for i, entry := range vault.Entries {
+		if entry.ID == id {
+			index = i
+			break
+		}
+	}
+
+	switch {
+	case c.Before == nil:
+		if index >= 0 || TitleInUse(vault, c.After.Folder, c.After.Title) {
+			return ErrEntryConflict
+		}
+		vault.Entries = append(vault.Entries, *c.After)
+	case index < 0 || !sameEntry(vault.Entries[index], *c.Before):
+		return ErrEntryConflict
+	case c.After == nil:
+		vault.Entries = append(vault.Entries[:index], vault.Entries[index+1:]...)
+		if c.Trashed != nil {
+			vault.Trash = append(vault.Trash, *c.Trashed)
+		}
+	default:
+		renamed := c.After.Folder != c.Before.Folder || c.After.Title != c.Before.Title
+		if renamed && TitleInUse(vault, c.After.Folder, c.After.Title) {
+			return ErrEntryConflict
+		}
+		vault.Entries[index] = *c.After
+	}
+	return nil
+}
+
+// sameEntry reports whether two entries are identical. They are compared in
+// their stored form, so the check keeps up with new entry fields.
+func sameEntry(a, b models.PasswordEntry) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"errors"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+	"time"
+)
+
+func TestSaveVault_IncrementsRevision(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	for want := uint64(1); want <= 2; want++ {
+		if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+			t.Fatalf("SaveVault failed: %v", err)
+		}
+		if vault.Revision != want {
+			t.Errorf("Expected revision %d, got %d", want, vault.Revision)
+		}
+	}
+
+	loaded, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if loaded.Revision != 2 {
+		t.Errorf("Expected stored revision 2, got %d", loaded.Revision)
+	}
+}
+
+func TestSaveVaultChecked_DetectsConflict(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	password := crypto.SecretFromString("password")
+	setup := NewStorage(tempDir)
+	setup.Initialize()
+	if err := setup.SaveVault(&models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}, password); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	// Two sessions open the same revision
+	first, second := NewStorage(tempDir), NewStorage(tempDir)
+	firstVault, _ := first.LoadVault(password)
+	secondVault, _ := second.LoadVault(password)
+
+	firstEntry := models.PasswordEntry{ID: "1", Title: "First"}
+	firstVault.Entries = append(firstVault.Entries, firstEntry)
+	if err := first.SaveVaultChecked(firstVault, password); err != nil {
+		t.Fatalf("First SaveVaultChecked failed: %v", err)
+	}
+
+	secondEntry := models.PasswordEntry{ID: "2", Title: "Second"}
+	secondVault.Entries = append(secondVault.Entries, secondEntry)
+	err := second.SaveVaultChecked(secondVault, password)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrVaultConflict) {
+		t.Fatalf("Expected a ConflictError, got %v", err)
+	}
+	if len(conflict.Latest.Entries) != 1 || conflict.Latest.Entries[0].Title != "First" {
+		t.Fatalf("Conflict should carry the latest vault, got %+v", conflict.Latest.Entries)
+	}
+
+	if err := (EntryChange{After: &secondEntry}).Apply(conflict.Latest); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if err := second.SaveVaultChecked(conflict.Latest, password); err != nil {
+		t.Fatalf("SaveVaultChecked after merge failed: %v", err)
+	}
+
+	merged, _ := NewStorage(tempDir).LoadVault(password)
+	if len(merged.Entries) != 2 {
+		t.Errorf("Expected both entries after merge, got %+v", merged.Entries)
+	}
+
+	// The same store saving twice in a row is not a conflict
+	if err := second.SaveVaultChecked(conflict.Latest, password); err != nil {
+		t.Errorf("Consecutive SaveVaultChecked failed: %v", err)
+	}
+}
+
+func TestEntryChange_Apply(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	original := models.PasswordEntry{ID: "1", Title: "Mail", Password: "old", CreatedAt: created, UpdatedAt: created}
+	updated := original
+	updated.Password = "new"
+	newVault := func() *models.PasswordVault {
+		return &models.PasswordVault{Entries: []models.PasswordEntry{original}}
+	}
+
+	vault := newVault()
+	if err := (EntryChange{Before: &original, After: &updated}).Apply(vault); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if vault.Entries[0].Password != "new" {
+		t.Errorf("Update not applied: %+v", vault.Entries[0])
+	}
+
+	vault = newVault()
+	if err := (EntryChange{Before: &original}).Apply(vault); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(vault.Entries) != 0 {
+		t.Errorf("Delete not applied: %+v", vault.Entries)
+	}
+
+	// The entry was edited elsewhere in the meantime
+	vault = newVault()
+	vault.Entries[0].Notes = "changed elsewhere"
+	if err := (EntryChange{Before: &original, After: &updated}).Apply(vault); !errors.Is(err, ErrEntryConflict) {
+		t.Errorf("Expected ErrEntryConflict for an edited entry, got %v", err)
+	}
+
+	// The entry was deleted elsewhere
+	vault = &models.PasswordVault{}
+	if err := (EntryChange{Before: &original, After: &updated}).Apply(vault); !errors.Is(err, ErrEntryConflict) {
+		t.Errorf("Expected ErrEntryConflict for a deleted entry, got %v", err)
+	}
+}
+
+func TestEntryChange_ApplyPathInUse(t *testing.T) {
+	existing := models.PasswordEntry{ID: "1", Title: "Mail", Folder: "work"}
+
+	// Another command added an entry with the same path in the meantime
+	added := models.PasswordEntry{ID: "2", Title: "Mail", Folder: "work"}
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{existing}}
+	if err := (EntryChange{After: &added}).Apply(vault); !errors.Is(err, ErrEntryConflict) {
+		t.Errorf("Expected ErrEntryConflict for a duplicate path, got %v", err)
+	}
+	if len(vault.Entries) != 1 {
+		t.Errorf("Duplicate entry was added: %+v", vault.Entries)
+	}
+
+	added.Folder = "home"
+	if err := (EntryChange{After: &added}).Apply(vault); err != nil {
+		t.Errorf("Same title in another folder failed: %v", err)
+	}
+
+	// Renaming an entry onto the path of another is a conflict too
+	renamed := added
+	renamed.Folder = "work"
+	if err := (EntryChange{Before: &added, After: &renamed}).Apply(vault); !errors.Is(err, ErrEntryConflict) {
+		t.Errorf("Expected ErrEntryConflict for a rename onto a used path, got %v", err)
+	}
+}
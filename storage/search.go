@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"passwordmanager/models"
+)
+
+// Searchable entry fields. Passwords are never searched.
+const (
+	FieldTitle    = "title"
+	FieldUsername = "username"
+	FieldURL      = "url"
+	FieldNotes    = "notes"
+	FieldTags     = "tags"
+)
+
+// fieldAliases maps the prefixes a query term can be scoped with to the
+// field they name
+var fieldAliases = map[string]string{
+	"title":    FieldTitle,
+	"user":     FieldUsername,
+	"username": FieldUsername,
+	"url":      FieldURL,
+	"note":     FieldNotes,
+	"notes":    FieldNotes,
+	"tag":      FieldTags,
+	"tags":     FieldTags,
+}
+
+// fieldWeights ranks a match in the title above one in the username, URL
+// or tags, and those above one in the notes
+var fieldWeights = map[string]int{
+	FieldTitle:    4,
+	FieldUsername: 3,
+	FieldURL:      2,
+	FieldTags:     2,
+	FieldNotes:    1,
+}
+
+// QueryTerm is one word of a search query. Field is empty for a term that
+// may match any field.
+type QueryTerm struct {
+	Field string
+	Text  string
+}
+
+// ParseQuery splits a search query into terms. A word such as url:github,
+// user:alice or tag:work only matches that field; any other word, including one
+// with an unknown prefix such as https://host, matches every field. Case
+// is ignored.
+func ParseQuery(query string) []QueryTerm {
+	var terms []QueryTerm
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		term := QueryTerm{Text: word}
+		if prefix, text, ok := strings.Cut(word, ":"); ok {
+			if field, known := fieldAliases[prefix]; known {
+				term = QueryTerm{Field: field, Text: text}
+			}
+		}
+		if term.Text != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// SearchResult is an entry that matched a search, by its index in
+// vault.Entries. A higher Score is a better match.
+type SearchResult struct {
+	Index int
+	Score int
+}
+
+// Search returns the entries that match every term, best match first.
+// Entries with the same score keep their order in the vault.
+func Search(vault *models.PasswordVault, terms []QueryTerm) []SearchResult {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []SearchResult
+	for i, entry := range vault.Entries {
+		// Each tag is matched on its own, so a term cannot span two tags
+		fields := map[string][]string{
+			FieldTitle:    {entry.Title},
+			FieldUsername: {entry.Username},
+			FieldURL:      {entry.URL},
+			FieldNotes:    {entry.Notes},
+			FieldTags:     entry.Tags,
+		}
+
+		total := 0
+		for _, term := range terms {
+			best := 0
+			for field, values := range fields {
+				if term.Field != "" && term.Field != field {
+					continue
+				}
+				for _, value := range values {
+					if score := fuzzyScore(strings.ToLower(value), term.Text) * fieldWeights[field]; score > best {
+						best = score
+					}
+				}
+			}
+			if best == 0 {
+				total = 0
+				break
+			}
+			total += best
+		}
+		if total > 0 {
+			results = append(results, SearchResult{Index: i, Score: total})
+		}
+	}
+
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Score > results[b].Score
+	})
+	return results
+}
+
+// fuzzyScore returns how well pattern matches text, both lower case, from
+// 100 for the whole text down to 1 for a scattered subsequence, or 0 if
+// the characters of pattern do not all appear in text in order
+func fuzzyScore(text, pattern string) int {
+	switch {
+	case pattern == "":
+		return 0
+	case text == pattern:
+		return 100
+	case strings.HasPrefix(text, pattern):
+		return 80
+	}
+	if i := strings.Index(text, pattern); i >= 0 {
+		if atWordStart(text, i) {
+			return 70
+		}
+		return 60
+	}
+
+	// Each character scores 1, plus 2 if it follows the previous match and
+	// 2 if it starts a word, scaled to at most 50
+	runes := []rune(text)
+	points, pos, previous := 0, 0, -2
+	for _, c := range pattern {
+		for pos < len(runes) && runes[pos] != c {
+			pos++
+		}
+		if pos == len(runes) {
+			return 0
+		}
+		points++
+		if pos == previous+1 {
+			points += 2
+		}
+		if pos == 0 || !isWordRune(runes[pos-1]) {
+			points += 2
+		}
+		previous = pos
+		pos++
+	}
+	return max(1, 50*points/(5*len([]rune(pattern))))
+}
+
+// atWordStart reports whether the byte offset i of text starts a word
+func atWordStart(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	before := []rune(text[:i])
+	return !isWordRune(before[len(before)-1])
+}
+
+// isWordRune reports whether r is part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
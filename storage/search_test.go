@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"passwordmanager/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	terms := ParseQuery("GitHub url:example.com USER:Alice https://host tags:x Tag:2FA pass:x note:")
+	want := []QueryTerm{
+		{Text: "github"},
+		{Field: FieldURL, Text: "example.com"},
+		{Field: FieldUsername, Text: "alice"},
+		{Text: "https://host"},
+		{Field: FieldTags, Text: "x"},
+		{Field: FieldTags, Text: "2fa"},
+		{Text: "pass:x"},
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("Expected %+v, got %+v", want, terms)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	ranked := []string{"github", "github enterprise", "my github", "mygithub", "gxixtxhxuxb"}
+	previous := 101
+	for _, text := range ranked {
+		score := fuzzyScore(text, "github")
+		if score <= 0 || score >= previous {
+			t.Errorf("Expected %q to score between 0 and %d, got %d", text, previous, score)
+		}
+		previous = score
+	}
+
+	if score := fuzzyScore("gitlab", "github"); score != 0 {
+		t.Errorf("Expected no match, got %d", score)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{
+		{Title: "Work mail", Username: "alice", URL: "https://mail.example.com", Notes: "github backup codes"},
+		{Title: "GitHub", Username: "alice", URL: "https://github.com"},
+		{Title: "GitHub", Username: "bob", URL: "https://github.com", Password: "alice"},
+	}}
+
+	search := func(query string) []int {
+		var indexes []int
+		for _, result := range Search(vault, ParseQuery(query)) {
+			indexes = append(indexes, result.Index)
+		}
+		return indexes
+	}
+
+	// A title match ranks above a match in the notes
+	if got := search("github"); !reflect.DeepEqual(got, []int{1, 2, 0}) {
+		t.Errorf("Unexpected results for github: %v", got)
+	}
+	// Every term must match, and passwords are not searched
+	if got := search("gthb alice"); !reflect.DeepEqual(got, []int{1, 0}) {
+		t.Errorf("Unexpected results for gthb alice: %v", got)
+	}
+	if got := search("url:github user:bob"); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("Unexpected results for url:github user:bob: %v", got)
+	}
+	if got := search("title:codes"); len(got) != 0 {
+		t.Errorf("Expected no results for title:codes, got %v", got)
+	}
+}
+
+func TestSearch_Tags(t *testing.T) {
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{
+		{Title: "Mail", Notes: "work account"},
+		{Title: "Bank", Tags: []string{"finance", "2FA"}},
+		{Title: "GitHub", Tags: []string{"work", "dev"}},
+		{Title: "Work VPN"},
+	}}
+
+	search := func(query string) []int {
+		var indexes []int
+		for _, result := range Search(vault, ParseQuery(query)) {
+			indexes = append(indexes, result.Index)
+		}
+		return indexes
+	}
+
+	// An unscoped term matches tags, ranked between titles and notes
+	if got := search("work"); !reflect.DeepEqual(got, []int{3, 2, 0}) {
+		t.Errorf("Unexpected results for work: %v", got)
+	}
+	if got := search("tag:work"); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("Unexpected results for tag:work: %v", got)
+	}
+	if got := search("tags:2fa bank"); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Unexpected results for tags:2fa bank: %v", got)
+	}
+	// A term does not match across two tags
+	if got := search("tag:workdev"); len(got) != 0 {
+		t.Errorf("Expected no results for tag:workdev, got %v", got)
+	}
+}
@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"passwordmanager/crypto"
 	"passwordmanager/models"
+	"time"
 )
 
 const (
@@ -14,85 +18,592 @@ const (
 	UserFileName  = "user.dat"
 )
 
+var (
+	// ErrInvalidPassword is returned when the vault cannot be opened with the
+	// supplied master password
+	ErrInvalidPassword = errors.New("invalid master password")
+
+	// ErrVaultCorrupted is returned when the data key was unwrapped but the
+	// vault contents fail authentication
+	ErrVaultCorrupted = errors.New("vault data is corrupted")
+
+	// ErrVaultLocked is returned by operations that need the data key before
+	// the vault has been unlocked
+	ErrVaultLocked = errors.New("vault is locked")
+
+	// ErrKeyFileRequired is returned when the vault can only be unlocked
+	// together with a key file and none was supplied
+	ErrKeyFileRequired = errors.New("this vault requires a key file")
+
+	// ErrKeyFileNotUsed is returned when a key file was supplied for a vault
+	// that is not protected by one
+	ErrKeyFileNotUsed = errors.New("this vault is not protected by a key file")
+
+	// ErrVaultMismatch is returned when vault.dat is not the vault user.dat
+	// was bound to
+	ErrVaultMismatch = errors.New("vault file does not belong to this user")
+
+	// ErrVaultRollback is returned when vault.dat is older than the last
+	// vault saved for this user
+	ErrVaultRollback = errors.New("vault file is older than the last saved vault")
+)
+
 type Storage struct {
-	dataDir string
+	backend Backend
+
+	// dataDir holds the lock file; without one, locking only nests
+	dataDir     string
+	kdfParams   *crypto.KDFParams
+	cipherSuite crypto.CipherSuite
+
+	// keyFile, when set, is required alongside the master password
+	keyFile *crypto.Secret
+
+	// dataKey and keyslots are populated once the vault has been unlocked,
+	// unlockedSlot is the index of the keyslot that opened it
+	dataKey      *crypto.Secret
+	keyslots     []Keyslot
+	unlockedSlot int
+
+	// vaultID and generation identify the vault file last read or written
+	vaultID    []byte
+	generation uint64
+
+	// lockTimeout bounds how long Lock waits, lockDepth counts nested calls
+	lockTimeout time.Duration
+	lockDepth   int
+
+	backupPolicy BackupPolicy
+
+	// trashRetention is how long deleted entries stay in the trash
+	trashRetention time.Duration
+
+	// pendingMigrations upgraded the loaded vault and are not saved yet
+	pendingMigrations []VaultMigration
 }
 
-// NewStorage creates a new storage instance
+// NewStorage creates a new storage instance keeping its files in dataDir
 func NewStorage(dataDir string) *Storage {
+	return NewStorageWithBackend(NewFileBackend(dataDir), dataDir)
+}
+
+// NewStorageWithBackend creates a storage instance on top of backend. The
+// lock file is kept in dataDir; pass an empty dataDir for a backend that no
+// other process can reach, such as a MemoryBackend.
+func NewStorageWithBackend(backend Backend, dataDir string) *Storage {
 	return &Storage{
-		dataDir: dataDir,
+		backend:      backend,
+		dataDir:      dataDir,
+		cipherSuite:  crypto.CipherAES256GCM,
+		unlockedSlot: -1,
+		lockTimeout:  DefaultLockTimeout,
+		backupPolicy: BackupPolicy{Keep: DefaultBackupKeep},
+
+		trashRetention: DefaultTrashRetention,
 	}
 }
 
 // Initialize creates the data directory if it doesn't exist
 func (s *Storage) Initialize() error {
+	if s.dataDir == "" {
+		return nil
+	}
 	return os.MkdirAll(s.dataDir, 0700)
 }
 
-// SaveVault encrypts and saves the password vault
-func (s *Storage) SaveVault(vault *models.PasswordVault, masterPassword string) error {
-	data, err := json.Marshal(vault)
+// SetKDFParams sets the key derivation parameters used for new password
+// keyslots. LoadVault replaces them with those of the vault on disk.
+func (s *Storage) SetKDFParams(params crypto.KDFParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	s.kdfParams = &params
+	return nil
+}
+
+// SetCipherSuite sets the cipher the vault contents are encrypted with by
+// the next SaveVault. LoadVault replaces it with the suite of the vault on
+// disk. Keyslots always wrap the data key with AES-256-GCM, so switching
+// suites never needs the unlock secrets.
+func (s *Storage) SetCipherSuite(suite crypto.CipherSuite) error {
+	if !suite.Valid() {
+		return fmt.Errorf("unsupported cipher suite: %s", suite)
+	}
+	s.cipherSuite = suite
+	return nil
+}
+
+// CipherSuite returns the cipher the vault contents are encrypted with
+func (s *Storage) CipherSuite() crypto.CipherSuite {
+	return s.cipherSuite
+}
+
+// SetKeyFile supplies key file contents that must accompany the master
+// password. Vaults created while a key file is set require it to unlock.
+// The contents are copied into locked memory; the caller should wipe its own
+// copy.
+func (s *Storage) SetKeyFile(contents []byte) error {
+	if len(contents) == 0 {
+		return fmt.Errorf("key file is empty")
+	}
+	s.keyFile.Wipe()
+	s.keyFile = crypto.NewSecret(append([]byte(nil), contents...))
+	return nil
+}
+
+// Wipe erases the data key and key file held by the store. The vault has to
+// be unlocked again before it can be saved.
+func (s *Storage) Wipe() {
+	s.dataKey.Wipe()
+	s.keyFile.Wipe()
+	s.dataKey = nil
+	s.keyFile = nil
+	s.keyslots = nil
+	s.unlockedSlot = -1
+}
+
+// passwordSlotType returns the keyslot type the master password unlocks,
+// which depends on whether a key file was supplied
+func (s *Storage) passwordSlotType() KeyslotType {
+	if s.keyFile != nil {
+		return KeyslotPasswordKeyFile
+	}
+	return KeyslotPassword
+}
+
+// SaveVault encrypts and saves the password vault under the vault data key,
+// in CurrentVaultVersion, and increments vault.Revision. Entries that have
+// been in the trash for longer than the retention period are purged.
+// masterPassword is only used when the vault has not been unlocked yet: it
+// unlocks the existing vault, or protects a newly created data key.
+// SaveVault overwrites whatever is on disk; see SaveVaultChecked.
+func (s *Storage) SaveVault(vault *models.PasswordVault, masterPassword *crypto.Secret) error {
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	s.purgeTrash(vault)
+	saved := *vault
+	saved.Version = CurrentVaultVersion
+	saved.Revision++
+	data, err := json.Marshal(&saved)
 	if err != nil {
 		return fmt.Errorf("failed to marshal vault: %w", err)
 	}
+	defer crypto.Wipe(data)
+
+	if s.dataKey == nil {
+		if err := s.prepareDataKey(masterPassword); err != nil {
+			return err
+		}
+	}
+
+	if s.vaultID == nil {
+		vaultID := make([]byte, VaultIDSize)
+		if _, err := io.ReadFull(rand.Reader, vaultID); err != nil {
+			return fmt.Errorf("failed to generate vault ID: %w", err)
+		}
+		s.vaultID = vaultID
+	}
+
+	header := &VaultHeader{
+		FormatVersion: CurrentFormatVersion,
+		CipherSuite:   s.cipherSuite,
+		VaultID:       s.vaultID,
+		Generation:    s.generation + 1,
+		Keyslots:      s.keyslots,
+	}
+	headerBytes, err := header.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode vault header: %w", err)
+	}
 
-	encryptedData, err := crypto.EncryptData(data, masterPassword)
+	ciphertext, err := s.cipherSuite.Seal(s.dataKey.Bytes(), data, header.additionalData(headerBytes))
 	if err != nil {
 		return fmt.Errorf("failed to encrypt vault: %w", err)
 	}
 
-	vaultPath := filepath.Join(s.dataDir, VaultFileName)
-	return os.WriteFile(vaultPath, encryptedData, 0600)
+	if err := s.backupVault(); err != nil {
+		return fmt.Errorf("failed to back up vault: %w", err)
+	}
+
+	if _, err := s.backend.Put(VaultFileName, append(headerBytes, ciphertext...)); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	s.generation = header.Generation
+	vault.Version = saved.Version
+	vault.Revision = saved.Revision
+	s.pendingMigrations = nil
+
+	if err := s.refreshBinding(); err != nil {
+		return fmt.Errorf("failed to update user configuration: %w", err)
+	}
+
+	// The vault is saved; failing to prune old backups only costs disk space
+	s.PruneBackups(s.backupPolicy)
+
+	// Keyslots that were just removed must not survive in the backups
+	if err := s.rekeyBackups(); err != nil {
+		return fmt.Errorf("vault saved, but failed to update backups: %w", err)
+	}
+	return nil
 }
 
-// LoadVault loads and decrypts the password vault
-func (s *Storage) LoadVault(masterPassword string) (*models.PasswordVault, error) {
-	vaultPath := filepath.Join(s.dataDir, VaultFileName)
-	
-	encryptedData, err := os.ReadFile(vaultPath)
+// prepareDataKey unlocks the data key of an existing keyslot vault, or
+// creates a new data key protected by masterPassword
+func (s *Storage) prepareDataKey(masterPassword *crypto.Secret) error {
+	if s.VaultExists() {
+		header, err := s.ReadVaultHeader()
+		if err != nil {
+			return err
+		}
+		if header.FormatVersion >= FormatKeyslots {
+			return s.unlock(header, s.passwordSlotType(), masterPassword)
+		}
+	}
+	return s.createDataKey(masterPassword)
+}
+
+// createDataKey generates a fresh data key with a single password keyslot
+func (s *Storage) createDataKey(masterPassword *crypto.Secret) error {
+	if s.kdfParams == nil {
+		params, err := crypto.DefaultKDFParams(crypto.KDFArgon2id)
+		if err != nil {
+			return fmt.Errorf("failed to generate KDF parameters: %w", err)
+		}
+		s.kdfParams = &params
+	}
+
+	dataKey, err := newDataKey()
 	if err != nil {
-		if os.IsNotExist(err) {
+		return fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	slot, err := newKeyslot(s.passwordSlotType(), masterPassword, s.keyFile, *s.kdfParams, dataKey)
+	if err != nil {
+		crypto.Wipe(dataKey)
+		return err
+	}
+
+	s.dataKey.Wipe()
+	s.dataKey = crypto.NewSecret(dataKey)
+	s.keyslots = []Keyslot{slot}
+	s.vaultID = nil
+	s.generation = 0
+	return nil
+}
+
+// unlock unwraps the data key using a secret of the given keyslot type
+func (s *Storage) unlock(header *VaultHeader, slotType KeyslotType, secret *crypto.Secret) error {
+	switch {
+	case slotType == KeyslotPassword && !hasKeyslot(header.Keyslots, KeyslotPassword) &&
+		hasKeyslot(header.Keyslots, KeyslotPasswordKeyFile):
+		return ErrKeyFileRequired
+	case slotType == KeyslotPasswordKeyFile && !hasKeyslot(header.Keyslots, KeyslotPasswordKeyFile):
+		return ErrKeyFileNotUsed
+	}
+
+	dataKey, index, err := unlockKeyslots(header.Keyslots, slotType, secret, s.keyFile)
+	if err != nil {
+		if errors.Is(err, errKeyslotMismatch) {
+			return ErrInvalidPassword
+		}
+		return err
+	}
+
+	s.dataKey.Wipe()
+	s.dataKey = crypto.NewSecret(dataKey)
+	s.keyslots = header.Keyslots
+	s.unlockedSlot = index
+	s.cipherSuite = header.CipherSuite
+	s.vaultID = header.VaultID
+	s.generation = header.Generation
+	for _, slot := range header.Keyslots {
+		if slot.Type == KeyslotPassword || slot.Type == KeyslotPasswordKeyFile {
+			params := slot.KDF
+			s.kdfParams = &params
+			break
+		}
+	}
+	return nil
+}
+
+// ChangeMasterPassword replaces the password keyslot with one derived from
+// newPassword, and the key file if one is set, and saves the vault. The KDF
+// algorithm and cost are kept but a fresh salt is used. Only the data key is
+// rewrapped; the file is replaced atomically, so after a crash vault.dat
+// opens with exactly one of the two passwords. The backups are then sealed
+// again without the old password's keyslot, see rekeyBackups.
+func (s *Storage) ChangeMasterPassword(vault *models.PasswordVault, newPassword *crypto.Secret) error {
+	if s.dataKey == nil {
+		return ErrVaultLocked
+	}
+
+	if s.kdfParams == nil {
+		params, err := crypto.DefaultKDFParams(crypto.KDFArgon2id)
+		if err != nil {
+			return fmt.Errorf("failed to generate KDF parameters: %w", err)
+		}
+		s.kdfParams = &params
+	}
+
+	params := *s.kdfParams
+	salt, err := crypto.NewKDFSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate KDF salt: %w", err)
+	}
+	params.Salt = salt
+
+	if err := s.RemoveKeyslots(KeyslotPassword); err != nil {
+		return err
+	}
+	if err := s.RemoveKeyslots(KeyslotPasswordKeyFile); err != nil {
+		return err
+	}
+	if err := s.AddKeyslot(s.passwordSlotType(), newPassword, params); err != nil {
+		return err
+	}
+	s.kdfParams = &params
+
+	return s.SaveVault(vault, newPassword)
+}
+
+// AddKeyslot wraps the unlocked data key under secret so that it can also
+// unlock the vault. KeyslotPasswordKeyFile slots also use the key file set
+// with SetKeyFile. The change is written by the next SaveVault.
+func (s *Storage) AddKeyslot(slotType KeyslotType, secret *crypto.Secret, params crypto.KDFParams) error {
+	if s.dataKey == nil {
+		return ErrVaultLocked
+	}
+	if len(s.keyslots) >= 255 {
+		return fmt.Errorf("too many keyslots")
+	}
+
+	if slotType == KeyslotPasswordKeyFile && s.keyFile == nil {
+		return fmt.Errorf("no key file set")
+	}
+
+	slot, err := newKeyslot(slotType, secret, s.keyFile, params, s.dataKey.Bytes())
+	if err != nil {
+		return err
+	}
+	s.keyslots = append(s.keyslots, slot)
+	return nil
+}
+
+// RemoveKeyslots drops every keyslot of the given type. The change is
+// written by the next SaveVault.
+func (s *Storage) RemoveKeyslots(slotType KeyslotType) error {
+	if s.dataKey == nil {
+		return ErrVaultLocked
+	}
+
+	kept := make([]Keyslot, 0, len(s.keyslots))
+	for _, slot := range s.keyslots {
+		if slot.Type != slotType {
+			kept = append(kept, slot)
+		}
+	}
+	s.keyslots = kept
+	return nil
+}
+
+// Keyslots returns the keyslots of the unlocked vault
+func (s *Storage) Keyslots() []Keyslot {
+	return append([]Keyslot(nil), s.keyslots...)
+}
+
+// LoadVault loads and decrypts the password vault. Vaults written in an older
+// format are upgraded to CurrentFormatVersion as soon as they are opened.
+// A save that was interrupted after its data reached the disk is completed
+// first.
+func (s *Storage) LoadVault(masterPassword *crypto.Secret) (*models.PasswordVault, error) {
+	return s.loadVault(s.passwordSlotType(), masterPassword)
+}
+
+// loadVault opens the vault with a secret of the given keyslot type
+func (s *Storage) loadVault(slotType KeyslotType, secret *crypto.Secret) (*models.PasswordVault, error) {
+	if err := s.Lock(); err != nil {
+		return nil, err
+	}
+	defer s.Unlock()
+
+	if err := s.recoverInterruptedSave(slotType, secret); err != nil {
+		return nil, err
+	}
+
+	encryptedData, _, err := s.backend.Get(VaultFileName)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
 			// Return empty vault if file doesn't exist
 			return &models.PasswordVault{
 				Entries: []models.PasswordEntry{},
-				Version: "1.0",
+				Version: CurrentVaultVersion,
 			}, nil
 		}
 		return nil, fmt.Errorf("failed to read vault file: %w", err)
 	}
 
-	data, err := crypto.DecryptData(encryptedData, masterPassword)
+	format := detectFormat(encryptedData)
+	if format < FormatKeyslots && slotType == KeyslotPasswordKeyFile {
+		return nil, ErrKeyFileNotUsed
+	}
+	if format < FormatKeyslots && slotType != KeyslotPassword {
+		return nil, fmt.Errorf("vault format version %d only supports unlocking with the master password", format)
+	}
+	if format < FormatBound {
+		if err := s.checkUnbound(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case FormatLegacy:
+		data, err := crypto.DecryptLegacyData(encryptedData, secret)
+		if err != nil {
+			return nil, decryptError(err)
+		}
+		return s.upgradeVault(data, secret)
+	case FormatKDF:
+		params, _, err := crypto.SplitKDFParams(encryptedData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault header: %w", err)
+		}
+		data, err := crypto.DecryptData(encryptedData, secret, crypto.AADContext{})
+		if err != nil {
+			return nil, decryptError(err)
+		}
+		s.kdfParams = &params
+		return s.upgradeVault(data, secret)
+	}
+
+	header, headerBytes, ciphertext, err := parseVaultHeader(encryptedData)
 	if err != nil {
+		return nil, fmt.Errorf("failed to read vault header: %w", err)
+	}
+
+	if header.FormatVersion == FormatEnvelope {
+		key, err := crypto.DeriveKey(secret, header.KDF)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive vault key: %w", err)
+		}
+		data, err := header.CipherSuite.Open(key, ciphertext, header.additionalData(headerBytes))
+		crypto.Wipe(key)
+		if err != nil {
+			return nil, decryptError(err)
+		}
+		s.kdfParams = &header.KDF
+		return s.upgradeVault(data, secret)
+	}
+
+	if err := s.unlock(header, slotType, secret); err != nil {
+		return nil, err
+	}
+
+	data, err := header.CipherSuite.Open(s.dataKey.Bytes(), ciphertext, header.additionalData(headerBytes))
+	if err != nil {
+		if errors.Is(err, crypto.ErrAuthFailed) {
+			return nil, ErrVaultCorrupted
+		}
 		return nil, fmt.Errorf("failed to decrypt vault: %w", err)
 	}
 
-	var vault models.PasswordVault
-	if err := json.Unmarshal(data, &vault); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal vault: %w", err)
+	vault, applied, err := decodeVault(data)
+	if err != nil {
+		return nil, err
+	}
+	s.pendingMigrations = applied
+	s.purgeTrash(vault)
+
+	if header.FormatVersion < CurrentFormatVersion {
+		if err := s.SaveVault(vault, secret); err != nil {
+			return nil, fmt.Errorf("failed to upgrade vault format: %w", err)
+		}
+		return vault, nil
+	}
+	if err := s.checkBinding(header); err != nil {
+		return nil, err
+	}
+	return vault, nil
+}
+
+// ReadVaultHeader returns the header of the vault file without decrypting it.
+// Files that predate the envelope are reported with their detected
+// FormatVersion and, for FormatKDF, their inline KDF parameters.
+func (s *Storage) ReadVaultHeader() (*VaultHeader, error) {
+	data, _, err := s.backend.Get(VaultFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault file: %w", err)
+	}
+
+	switch detectFormat(data) {
+	case FormatLegacy:
+		return &VaultHeader{FormatVersion: FormatLegacy, CipherSuite: crypto.CipherAES256GCM}, nil
+	case FormatKDF:
+		params, _, err := crypto.SplitKDFParams(data)
+		if err != nil {
+			return nil, err
+		}
+		return &VaultHeader{FormatVersion: FormatKDF, CipherSuite: crypto.CipherAES256GCM, KDF: params}, nil
+	}
+
+	header, _, _, err := parseVaultHeader(data)
+	return header, err
+}
+
+// upgradeVault decodes a vault read from an older file format and rewrites
+// it in the current format
+func (s *Storage) upgradeVault(data []byte, masterPassword *crypto.Secret) (*models.PasswordVault, error) {
+	vault, _, err := decodeVault(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.createDataKey(masterPassword); err != nil {
+		return nil, fmt.Errorf("failed to upgrade vault format: %w", err)
+	}
+	if err := s.SaveVault(vault, masterPassword); err != nil {
+		return nil, fmt.Errorf("failed to upgrade vault format: %w", err)
 	}
 
-	return &vault, nil
+	return vault, nil
+}
+
+// decryptError reports an authentication failure as ErrInvalidPassword
+func decryptError(err error) error {
+	if errors.Is(err, crypto.ErrAuthFailed) {
+		return ErrInvalidPassword
+	}
+	return fmt.Errorf("failed to decrypt vault: %w", err)
 }
 
-// SaveUser saves user configuration
+// SaveUser saves user configuration. Once the vault is unlocked the user is
+// bound to it, see models.VaultBinding.
 func (s *Storage) SaveUser(user *models.User) error {
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	s.bindUser(user)
+
 	data, err := json.Marshal(user)
 	if err != nil {
 		return fmt.Errorf("failed to marshal user: %w", err)
 	}
 
-	userPath := filepath.Join(s.dataDir, UserFileName)
-	return os.WriteFile(userPath, data, 0600)
+	if _, err := s.backend.Put(UserFileName, data); err != nil {
+		return fmt.Errorf("failed to write user file: %w", err)
+	}
+	return nil
 }
 
 // LoadUser loads user configuration
 func (s *Storage) LoadUser() (*models.User, error) {
-	userPath := filepath.Join(s.dataDir, UserFileName)
-	
-	data, err := os.ReadFile(userPath)
+	data, _, err := s.backend.Get(UserFileName)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, ErrBlobNotFound) {
 			return nil, nil // No user file exists yet
 		}
 		return nil, fmt.Errorf("failed to read user file: %w", err)
@@ -106,16 +617,49 @@ func (s *Storage) LoadUser() (*models.User, error) {
 	return &user, nil
 }
 
+// UpgradeUser removes the fast master password verifier that older versions
+// stored in user.dat. Where a vault exists it is the verifier from now on.
+// Installs that never saved a vault have nothing else to check against, so
+// the old hash is checked one last time and an empty vault is created.
+func (s *Storage) UpgradeUser(masterPassword *crypto.Secret) error {
+	user, err := s.LoadUser()
+	if err != nil {
+		return err
+	}
+	if user == nil || user.MasterPasswordHash == "" {
+		return nil
+	}
+
+	if !s.VaultExists() {
+		// The old verifier hashed a string, so this one copy cannot be
+		// wiped; it only happens once per install
+		computedHash := crypto.HashPassword(string(masterPassword.Bytes()), user.Salt)
+		if subtle.ConstantTimeCompare([]byte(computedHash), []byte(user.MasterPasswordHash)) != 1 {
+			return ErrInvalidPassword
+		}
+
+		vault := &models.PasswordVault{
+			Entries: []models.PasswordEntry{},
+			Version: CurrentVaultVersion,
+		}
+		if err := s.SaveVault(vault, masterPassword); err != nil {
+			return fmt.Errorf("failed to create vault: %w", err)
+		}
+	}
+
+	user.MasterPasswordHash = ""
+	user.Salt = ""
+	return s.SaveUser(user)
+}
+
 // UserExists checks if a user configuration exists
 func (s *Storage) UserExists() bool {
-	userPath := filepath.Join(s.dataDir, UserFileName)
-	_, err := os.Stat(userPath)
-	return !os.IsNotExist(err)
+	_, err := s.backend.Stat(UserFileName)
+	return !errors.Is(err, ErrBlobNotFound)
 }
 
 // VaultExists checks if a vault file exists
 func (s *Storage) VaultExists() bool {
-	vaultPath := filepath.Join(s.dataDir, VaultFileName)
-	_, err := os.Stat(vaultPath)
-	return !os.IsNotExist(err)
+	_, err := s.backend.Stat(VaultFileName)
+	return !errors.Is(err, ErrBlobNotFound)
 }
@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"errors"
 	"os"
 	"path/filepath"
+	"passwordmanager/crypto"
 	"passwordmanager/models"
+	"strings"
 	"testing"
 	"time"
 )
@@ -110,7 +114,7 @@ func TestSaveVault_LoadVault(t *testing.T) {
 	}
 
 	// Save vault
-	err = store.SaveVault(vault, masterPassword)
+	err = store.SaveVault(vault, crypto.SecretFromString(masterPassword))
 	if err != nil {
 		t.Fatalf("SaveVault returned error: %v", err)
 	}
@@ -130,7 +134,7 @@ func TestSaveVault_LoadVault(t *testing.T) {
 	}
 
 	// Load vault
-	loadedVault, err := store.LoadVault(masterPassword)
+	loadedVault, err := store.LoadVault(crypto.SecretFromString(masterPassword))
 	if err != nil {
 		t.Fatalf("LoadVault returned error: %v", err)
 	}
@@ -175,7 +179,7 @@ func TestLoadVault_NonExistent(t *testing.T) {
 		t.Fatalf("Initialize failed: %v", err)
 	}
 
-	vault, err := store.LoadVault("password")
+	vault, err := store.LoadVault(crypto.SecretFromString("password"))
 	if err != nil {
 		t.Fatalf("LoadVault returned error for non-existent vault: %v", err)
 	}
@@ -189,8 +193,8 @@ func TestLoadVault_NonExistent(t *testing.T) {
 		t.Errorf("Expected empty vault, got %d entries", len(vault.Entries))
 	}
 
-	if vault.Version != "1.0" {
-		t.Errorf("Expected version 1.0, got %s", vault.Version)
+	if vault.Version != CurrentVaultVersion {
+		t.Errorf("Expected version %s, got %s", CurrentVaultVersion, vault.Version)
 	}
 }
 
@@ -209,13 +213,13 @@ func TestLoadVault_WrongPassword(t *testing.T) {
 		Version: "1.0",
 	}
 
-	err = store.SaveVault(vault, "correctpassword")
+	err = store.SaveVault(vault, crypto.SecretFromString("correctpassword"))
 	if err != nil {
 		t.Fatalf("SaveVault failed: %v", err)
 	}
 
 	// Try to load with wrong password
-	_, err = store.LoadVault("wrongpassword")
+	_, err = store.LoadVault(crypto.SecretFromString("wrongpassword"))
 	if err == nil {
 		t.Error("LoadVault with wrong password should return error")
 	}
@@ -350,7 +354,7 @@ func TestVaultExists(t *testing.T) {
 		Version: "1.0",
 	}
 
-	err = store.SaveVault(vault, "password")
+	err = store.SaveVault(vault, crypto.SecretFromString("password"))
 	if err != nil {
 		t.Fatalf("SaveVault failed: %v", err)
 	}
@@ -403,12 +407,12 @@ func TestSaveVault_MultipleEntries(t *testing.T) {
 		Version: "1.0",
 	}
 
-	err = store.SaveVault(vault, masterPassword)
+	err = store.SaveVault(vault, crypto.SecretFromString(masterPassword))
 	if err != nil {
 		t.Fatalf("SaveVault failed: %v", err)
 	}
 
-	loadedVault, err := store.LoadVault(masterPassword)
+	loadedVault, err := store.LoadVault(crypto.SecretFromString(masterPassword))
 	if err != nil {
 		t.Fatalf("LoadVault failed: %v", err)
 	}
@@ -442,12 +446,12 @@ func TestSaveVault_EmptyVault(t *testing.T) {
 		Version: "1.0",
 	}
 
-	err = store.SaveVault(vault, "password")
+	err = store.SaveVault(vault, crypto.SecretFromString("password"))
 	if err != nil {
 		t.Fatalf("SaveVault failed: %v", err)
 	}
 
-	loadedVault, err := store.LoadVault("password")
+	loadedVault, err := store.LoadVault(crypto.SecretFromString("password"))
 	if err != nil {
 		t.Fatalf("LoadVault failed: %v", err)
 	}
@@ -484,7 +488,7 @@ func TestSaveVault_UpdateExisting(t *testing.T) {
 		Version: "1.0",
 	}
 
-	err = store.SaveVault(vault1, masterPassword)
+	err = store.SaveVault(vault1, crypto.SecretFromString(masterPassword))
 	if err != nil {
 		t.Fatalf("SaveVault failed: %v", err)
 	}
@@ -504,13 +508,13 @@ func TestSaveVault_UpdateExisting(t *testing.T) {
 		Version: "1.0",
 	}
 
-	err = store.SaveVault(vault2, masterPassword)
+	err = store.SaveVault(vault2, crypto.SecretFromString(masterPassword))
 	if err != nil {
 		t.Fatalf("SaveVault failed on update: %v", err)
 	}
 
 	// Verify update
-	loadedVault, err := store.LoadVault(masterPassword)
+	loadedVault, err := store.LoadVault(crypto.SecretFromString(masterPassword))
 	if err != nil {
 		t.Fatalf("LoadVault failed: %v", err)
 	}
@@ -528,3 +532,190 @@ func TestSaveVault_UpdateExisting(t *testing.T) {
 	}
 }
 
+
+func TestLoadVault_RekeysLegacyVault(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	err := store.Initialize()
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	// Write a vault in the original unsalted SHA-256 format
+	plaintext := []byte(`{"entries":[{"id":"1","title":"Legacy"}],"version":"1.0"}`)
+	key := sha256.Sum256([]byte("password"))
+	encrypted, err := crypto.Seal(key[:], plaintext, nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	vaultPath := filepath.Join(tempDir, VaultFileName)
+	if err := os.WriteFile(vaultPath, encrypted, 0600); err != nil {
+		t.Fatalf("Failed to write legacy vault: %v", err)
+	}
+
+	vault, err := store.LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault returned error: %v", err)
+	}
+	if len(vault.Entries) != 1 || vault.Entries[0].Title != "Legacy" {
+		t.Fatalf("Unexpected legacy vault contents: %+v", vault.Entries)
+	}
+
+	// The file on disk should now be in the current format
+	header, err := store.ReadVaultHeader()
+	if err != nil {
+		t.Fatalf("ReadVaultHeader failed: %v", err)
+	}
+	if header.FormatVersion != CurrentFormatVersion {
+		t.Errorf("Expected format version %d, got %d", CurrentFormatVersion, header.FormatVersion)
+	}
+	if header.Keyslots[0].KDF.Algorithm != crypto.KDFArgon2id {
+		t.Errorf("Expected argon2id, got %s", header.Keyslots[0].KDF.Algorithm)
+	}
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("Re-keyed vault could not be loaded: %v", err)
+	}
+}
+
+func TestLoadVault_WrongPasswordIsErrInvalidPassword(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("correctpassword")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	_, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("wrongpassword"))
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Expected ErrInvalidPassword, got %v", err)
+	}
+}
+
+func TestUpgradeUser_DropsLegacyHash(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Version: "1.0"}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	user := &models.User{
+		MasterPasswordHash: crypto.HashPassword("password", "salt"),
+		Salt:               "salt",
+		CreatedAt:          time.Now(),
+	}
+	if err := store.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	if err := store.UpgradeUser(crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("UpgradeUser returned error: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(tempDir, UserFileName))
+	if strings.Contains(string(data), "master_password_hash") || strings.Contains(string(data), "salt") {
+		t.Errorf("user.dat still contains the legacy verifier: %s", data)
+	}
+}
+
+func TestUpgradeUser_WithoutVault(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	user := &models.User{
+		MasterPasswordHash: crypto.HashPassword("password", "salt"),
+		Salt:               "salt",
+		CreatedAt:          time.Now(),
+	}
+	if err := store.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	if err := store.UpgradeUser(crypto.SecretFromString("wrong")); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Expected ErrInvalidPassword, got %v", err)
+	}
+	if store.VaultExists() {
+		t.Fatal("Vault should not be created for a wrong password")
+	}
+
+	if err := store.UpgradeUser(crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("UpgradeUser returned error: %v", err)
+	}
+	if !store.VaultExists() {
+		t.Fatal("UpgradeUser should create a vault to verify future unlocks")
+	}
+	if _, err := store.LoadVault(crypto.SecretFromString("wrong")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("New vault should reject the wrong password, got %v", err)
+	}
+
+	loaded, _ := store.LoadUser()
+	if loaded.MasterPasswordHash != "" || loaded.Salt != "" {
+		t.Error("Legacy verifier was not removed")
+	}
+}
+
+func TestChangeMasterPassword(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+
+	vault := &models.PasswordVault{
+		Entries: []models.PasswordEntry{{ID: "1", Title: "Entry", Password: "secret"}},
+		Version: "1.0",
+	}
+	if err := store.SaveVault(vault, crypto.SecretFromString("oldpassword")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+	oldHeader, _ := store.ReadVaultHeader()
+
+	loaded, err := store.LoadVault(crypto.SecretFromString("oldpassword"))
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if err := store.ChangeMasterPassword(loaded, crypto.SecretFromString("newpassword")); err != nil {
+		t.Fatalf("ChangeMasterPassword returned error: %v", err)
+	}
+
+	if _, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("oldpassword")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Old password should no longer open the vault, got %v", err)
+	}
+
+	reopened, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("newpassword"))
+	if err != nil {
+		t.Fatalf("New password should open the vault: %v", err)
+	}
+	if len(reopened.Entries) != 1 || reopened.Entries[0].Password != "secret" {
+		t.Errorf("Vault contents changed: %+v", reopened.Entries)
+	}
+
+	newHeader, _ := store.ReadVaultHeader()
+	if string(newHeader.Keyslots[0].KDF.Salt) == string(oldHeader.Keyslots[0].KDF.Salt) {
+		t.Error("ChangeMasterPassword should use a fresh KDF salt")
+	}
+	if newHeader.Keyslots[0].KDF.Algorithm != oldHeader.Keyslots[0].KDF.Algorithm || newHeader.Keyslots[0].KDF.Memory != oldHeader.Keyslots[0].KDF.Memory {
+		t.Error("ChangeMasterPassword should keep the KDF algorithm and cost")
+	}
+
+	// No temporary files may be left behind by the atomic replace
+	files, _ := os.ReadDir(tempDir)
+	for _, f := range files {
+		if strings.Contains(f.Name(), ".tmp-") {
+			t.Errorf("Temporary file left behind: %s", f.Name())
+		}
+	}
+}
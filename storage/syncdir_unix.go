@@ -0,0 +1,17 @@
+//go:build !windows
+
+package storage
+
+import (
+	"os"
+)
+
+// syncDir flushes directory entries, such as a rename, to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package storage
+
+// syncDir is a no-op on Windows, where directories cannot be opened for
+// syncing and renames are journaled by NTFS
+func syncDir(dir string) error {
+	return nil
+}
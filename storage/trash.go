@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"time"
+
+	"passwordmanager/models"
+)
+
+// DefaultTrashRetention is how long deleted entries stay in the trash
+// unless configured otherwise
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// SetTrashRetention sets how long deleted entries stay in the trash before
+// LoadVault and SaveVault purge them. A retention of 0 keeps them until the
+// trash is emptied.
+func (s *Storage) SetTrashRetention(retention time.Duration) {
+	s.trashRetention = retention
+}
+
+// purgeTrash drops the entries whose retention has run out from vault
+func (s *Storage) purgeTrash(vault *models.PasswordVault) {
+	PurgeTrash(vault, s.trashRetention, time.Now())
+}
+
+// TrashEntry moves the entry at index from vault.Entries to vault.Trash,
+// deleted at now, and returns it
+func TrashEntry(vault *models.PasswordVault, index int, now time.Time) models.TrashedEntry {
+	trashed := models.TrashedEntry{PasswordEntry: vault.Entries[index], DeletedAt: now}
+	vault.Entries = append(vault.Entries[:index], vault.Entries[index+1:]...)
+	vault.Trash = append(vault.Trash, trashed)
+	return trashed
+}
+
+// FindTrashed returns the index in vault.Trash of the entry ref names, by
+// ID, title, folder/title path or ID prefix as FindEntry does. It fails
+// with ErrEntryNotFound, or with an *AmbiguousEntryError whose Matches are
+// indexes in vault.Trash.
+func FindTrashed(vault *models.PasswordVault, ref string) (int, error) {
+	return findIn(TrashedEntries(vault), ref)
+}
+
+// TrashedEntries returns the entries in vault.Trash, in the same order
+func TrashedEntries(vault *models.PasswordVault) []models.PasswordEntry {
+	entries := make([]models.PasswordEntry, len(vault.Trash))
+	for i, trashed := range vault.Trash {
+		entries[i] = trashed.PasswordEntry
+	}
+	return entries
+}
+
+// RestoreTrashed moves the entry at index from vault.Trash back to
+// vault.Entries and returns it
+func RestoreTrashed(vault *models.PasswordVault, index int) models.PasswordEntry {
+	entry := vault.Trash[index].PasswordEntry
+	vault.Trash = append(vault.Trash[:index], vault.Trash[index+1:]...)
+	vault.Entries = append(vault.Entries, entry)
+	return entry
+}
+
+// TrashExpiry returns when a trashed entry is purged, or the zero time if
+// the retention is 0
+func TrashExpiry(trashed models.TrashedEntry, retention time.Duration) time.Time {
+	if retention <= 0 {
+		return time.Time{}
+	}
+	return trashed.DeletedAt.Add(retention)
+}
+
+// PurgeTrash removes the entries deleted longer than retention before now
+// from vault.Trash and returns them. A retention of 0 purges nothing.
+func PurgeTrash(vault *models.PasswordVault, retention time.Duration, now time.Time) []models.TrashedEntry {
+	if retention <= 0 || len(vault.Trash) == 0 {
+		return nil
+	}
+
+	var kept, purged []models.TrashedEntry
+	for _, trashed := range vault.Trash {
+		if now.Before(TrashExpiry(trashed, retention)) {
+			kept = append(kept, trashed)
+		} else {
+			purged = append(purged, trashed)
+		}
+	}
+	if len(purged) > 0 {
+		vault.Trash = kept
+	}
+	return purged
+}
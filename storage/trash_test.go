@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"passwordmanager/crypto"
+	"passwordmanager/models"
+	"testing"
+	"time"
+)
+
+func TestTrashEntry_AndRestore(t *testing.T) {
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{
+		{ID: "1", Title: "Mail"},
+		{ID: "2", Title: "Bank"},
+	}}
+	now := time.Now()
+
+	trashed := TrashEntry(vault, 0, now)
+	if trashed.ID != "1" || !trashed.DeletedAt.Equal(now) {
+		t.Errorf("Unexpected trashed entry %+v", trashed)
+	}
+	if len(vault.Entries) != 1 || len(vault.Trash) != 1 {
+		t.Fatalf("Expected 1 entry and 1 trashed entry, got %d and %d", len(vault.Entries), len(vault.Trash))
+	}
+
+	if _, err := FindTrashed(vault, "Bank"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("Bank is not in the trash, got %v", err)
+	}
+	index, err := FindTrashed(vault, "Mail")
+	if err != nil {
+		t.Fatalf("Mail should be in the trash: %v", err)
+	}
+	restored := RestoreTrashed(vault, index)
+	if restored.ID != "1" || len(vault.Entries) != 2 || len(vault.Trash) != 0 {
+		t.Errorf("Unexpected vault after restore: %+v", vault)
+	}
+}
+
+func TestFindTrashed(t *testing.T) {
+	now := time.Now()
+	vault := &models.PasswordVault{Trash: []models.TrashedEntry{
+		{PasswordEntry: models.PasswordEntry{ID: "7c1e2a40", Title: "Mail", Folder: "work"}, DeletedAt: now},
+		{PasswordEntry: models.PasswordEntry{ID: "9b03f5d1", Title: "Mail", Folder: "home"}, DeletedAt: now.Add(-time.Hour)},
+	}}
+
+	for ref, want := range map[string]int{"work/Mail": 0, "home/Mail": 1, "9b03f5d1": 1, "7c1e": 0} {
+		index, err := FindTrashed(vault, ref)
+		if err != nil || index != want {
+			t.Errorf("FindTrashed(%q) = %d, %v; want %d", ref, index, err, want)
+		}
+	}
+
+	var ambiguous *AmbiguousEntryError
+	if _, err := FindTrashed(vault, "Mail"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 2 {
+		t.Errorf("Expected both trashed Mail entries to match, got %v", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	now := time.Now()
+	vault := &models.PasswordVault{Trash: []models.TrashedEntry{
+		{PasswordEntry: models.PasswordEntry{ID: "1"}, DeletedAt: now.Add(-48 * time.Hour)},
+		{PasswordEntry: models.PasswordEntry{ID: "2"}, DeletedAt: now.Add(-time.Hour)},
+	}}
+
+	if purged := PurgeTrash(vault, 0, now); len(purged) != 0 {
+		t.Errorf("A retention of 0 should purge nothing, purged %d", len(purged))
+	}
+
+	purged := PurgeTrash(vault, 24*time.Hour, now)
+	if len(purged) != 1 || purged[0].ID != "1" {
+		t.Errorf("Expected entry 1 to be purged, got %+v", purged)
+	}
+	if len(vault.Trash) != 1 || vault.Trash[0].ID != "2" {
+		t.Errorf("Expected entry 2 to stay in the trash, got %+v", vault.Trash)
+	}
+}
+
+func TestSaveVault_PurgesTrash(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	store := NewStorage(tempDir)
+	store.Initialize()
+	store.SetTrashRetention(time.Hour)
+
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{}, Trash: []models.TrashedEntry{
+		{PasswordEntry: models.PasswordEntry{ID: "1"}, DeletedAt: time.Now().Add(-2 * time.Hour)},
+		{PasswordEntry: models.PasswordEntry{ID: "2"}, DeletedAt: time.Now()},
+	}}
+	if err := store.SaveVault(vault, crypto.SecretFromString("password")); err != nil {
+		t.Fatalf("SaveVault failed: %v", err)
+	}
+
+	loaded, err := NewStorage(tempDir).LoadVault(crypto.SecretFromString("password"))
+	if err != nil {
+		t.Fatalf("LoadVault failed: %v", err)
+	}
+	if len(loaded.Trash) != 1 || loaded.Trash[0].ID != "2" {
+		t.Errorf("Expected only entry 2 in the trash, got %+v", loaded.Trash)
+	}
+}
+
+func TestEntryChange_ApplyTrashed(t *testing.T) {
+	entry := models.PasswordEntry{ID: "1", Title: "Mail"}
+	trashed := models.TrashedEntry{PasswordEntry: entry, DeletedAt: time.Now()}
+	vault := &models.PasswordVault{Entries: []models.PasswordEntry{entry}}
+
+	if err := (EntryChange{Before: &entry, Trashed: &trashed}).Apply(vault); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(vault.Entries) != 0 || len(vault.Trash) != 1 || vault.Trash[0].ID != "1" {
+		t.Errorf("Expected the entry to move to the trash, got %+v", vault)
+	}
+}
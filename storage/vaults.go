@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultVaultName names the vault kept directly in the data directory
+const DefaultVaultName = "default"
+
+// VaultsDirName is the subdirectory of the data directory holding the
+// other named vaults, one directory each
+const VaultsDirName = "vaults"
+
+var vaultNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidateVaultName checks that name can be used as a vault name
+func ValidateVaultName(name string) error {
+	if !vaultNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid vault name %q: use up to 64 letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// VaultDir returns the directory of the named vault within dataDir. The
+// default vault lives in dataDir itself, as it did before vaults had names.
+func VaultDir(dataDir, name string) string {
+	if name == DefaultVaultName {
+		return dataDir
+	}
+	return filepath.Join(dataDir, VaultsDirName, name)
+}
+
+// ListVaults returns the names of the vaults in dataDir: the default vault
+// first, then the others sorted by name. A vault is listed as soon as its
+// directory exists, whether or not it has been initialized.
+func ListVaults(dataDir string) ([]string, error) {
+	names := []string{DefaultVaultName}
+
+	entries, err := os.ReadDir(filepath.Join(dataDir, VaultsDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, fmt.Errorf("failed to read vaults directory: %w", err)
+	}
+
+	var named []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultVaultName && ValidateVaultName(entry.Name()) == nil {
+			named = append(named, entry.Name())
+		}
+	}
+	sort.Strings(named)
+	return append(names, named...), nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateVaultName(t *testing.T) {
+	for _, name := range []string{"work", "client-42", "a.b_c"} {
+		if err := ValidateVaultName(name); err != nil {
+			t.Errorf("%q should be valid: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../work", "a/b", "-work", "with space"} {
+		if err := ValidateVaultName(name); err == nil {
+			t.Errorf("%q should be invalid", name)
+		}
+	}
+}
+
+func TestListVaults(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	names, err := ListVaults(tempDir)
+	if err != nil {
+		t.Fatalf("ListVaults failed: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{DefaultVaultName}) {
+		t.Errorf("Expected only the default vault, got %v", names)
+	}
+
+	for _, name := range []string{"work", "client"} {
+		if err := NewStorage(VaultDir(tempDir, name)).Initialize(); err != nil {
+			t.Fatalf("Initialize failed: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(tempDir, VaultsDirName, "notes.txt"), []byte("x"), 0600)
+
+	names, err = ListVaults(tempDir)
+	if err != nil {
+		t.Fatalf("ListVaults failed: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{DefaultVaultName, "client", "work"}) {
+		t.Errorf("Unexpected vaults %v", names)
+	}
+	if VaultDir(tempDir, DefaultVaultName) != tempDir {
+		t.Error("The default vault should live in the data directory")
+	}
+}